@@ -0,0 +1,542 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OPENAI_JSON_MODE の設定値
+const (
+	JSONModeAuto = "auto"
+	JSONModeOn   = "on"
+	JSONModeOff  = "off"
+)
+
+// SLACK_MODE の設定値
+const (
+	SlackModeSocket = "socket"
+	SlackModeHTTP   = "http"
+)
+
+// EMBEDDING_CACHE の設定値
+const (
+	EmbeddingCacheMemory = "memory"
+	EmbeddingCacheFile   = "file"
+)
+
+// 要約の文字数の既定値と許容範囲
+const (
+	defaultSummaryChars = 300
+	minSummaryChars     = 50
+	maxSummaryChars     = 1500
+)
+
+// JIRA_ORDER_BY 未指定時の並び順
+const defaultJiraOrderBy = "updated DESC"
+
+// Config はアプリケーションの全設定を保持する
+// 環境変数は LoadConfig でのみ読み込み、各コンポーネントはこの構造体から設定を受け取る
+type Config struct {
+	Slack     SlackConfig
+	Jira      JiraConfig
+	OpenAI    OpenAIConfig
+	Selection SelectionConfig
+	Handler   HandlerConfig
+}
+
+// SlackConfig はSlack関連の設定
+type SlackConfig struct {
+	// イベントの受信方式（Socket Mode または HTTP Events API）
+	Mode     string
+	BotToken string
+	// Socket Mode で使用するアプリレベルのトークン
+	AppToken string
+	// HTTP Events API の署名検証に使用するシークレット
+	SigningSecret string
+	// HTTP Events API の待ち受けアドレス
+	HTTPAddr  string
+	UserToken string
+	// スレッドURLの生成に使うワークスペースURL（空の場合は起動時に team.info から取得する）
+	WorkspaceURL string
+	// 応答・検索の対象とするチャンネル名（先頭の # は除去済み、空の場合は制限しない）
+	Channel string
+	// 要約の対象から除外するBotのユーザーID
+	ExcludeBots []string
+	// 検索対象とするチャンネルのIDまたは名前（空の場合は全チャンネル）
+	SearchAllowChannels []string
+	// 表示名のテンプレート
+	UserNameFormat string
+	// メンション無しでも応答するチャンネルのIDまたは名前
+	AutoRespondChannels []string
+	// 課題のサマリによる検索のモード（"and" / "or"、空の場合は無効）
+	SearchBySummary string
+	// URL検索とサマリ検索を合わせたスレッド数の上限
+	SearchMaxThreads int
+	// 同じスレッドに紐づく結果をまとめる
+	GroupByThread bool
+	// 結果を1つの親メッセージとそのスレッドにまとめて投稿する
+	CollapseResults bool
+	// 問い合わせチャンネルのトピック・説明を検索クエリ生成のヒントにする
+	UseChannelContext bool
+	// 途中経過を通知せず最終結果のみ投稿する
+	QuietMode bool
+	// QuietMode と同様に最終結果のみ投稿するチャンネル（IDまたは名前）
+	QuietChannels []string
+}
+
+// JiraConfig はJira関連の設定
+type JiraConfig struct {
+	Endpoint string
+	AuthType string
+	Username string
+	APIToken string
+	// JIRA_PROJECT_KEY の値（カンマ区切りで複数指定可能）
+	ProjectKey string
+	// 検索クエリ生成時の追加の指示
+	SearchQuery string
+	// 検索クエリに付与する並び順（空の場合は付与しない）
+	OrderBy string
+	// 検索結果に含まれないコメントを追加で取得する
+	FetchFullComments bool
+	// 問い合わせ者が担当者または報告者の課題を優先して検索する
+	SearchMineFirst bool
+	// キーワードのみを差し込むJQLのテンプレート（空の場合はLLMがJQL全体を生成する）
+	JQLTemplate string
+	// 使用するREST APIのバージョン（"2" / "3"、空の場合はserverInfoから自動判定する）
+	APIVersion string
+	// 検索レスポンスのrawJSONを書き出すディレクトリ（空の場合は書き出さない）
+	DumpDir string
+	// 本文・コメントをサーバ側でレンダリングされたHTMLから取得する
+	UseRendered bool
+	// 検索対象とする更新日の期間（日数、0の場合は制限しない）
+	SearchDays int
+	// 同一JQLの検索結果をキャッシュする期間（0の場合はキャッシュしない）
+	QueryCacheTTL time.Duration
+	// 生成されたJQLで使用を許可する関数（currentUser など既定で禁止している関数の許可）
+	AllowedFuncs []string
+}
+
+// ProjectKeys はカンマ区切りのプロジェクトキーを分割して返す
+func (c JiraConfig) ProjectKeys() []string {
+	return splitList(c.ProjectKey)
+}
+
+// OpenAIConfig はLLM関連の設定
+type OpenAIConfig struct {
+	APIKey          string
+	AzureEndpoint   string
+	AzureKey        string
+	AzureAPIVersion string
+	Model           string
+	// 課題の圧縮に使うモデル（未指定時は Model）
+	CondenseModel  string
+	EmbeddingModel string
+	JSONMode       string
+	// 要約の既定の出力言語（空の場合は問い合わせ本文から判定する）
+	SummaryLanguage        string
+	SummaryOverviewChars   int
+	SummaryResolutionChars int
+	EmbeddingCache         string
+	EmbeddingCacheDir      string
+	// プロンプトごとにLLMのレスポンスをファイルにキャッシュする（開発・テスト用）
+	ResponseCache    bool
+	ResponseCacheDir string
+	// Jira検索クエリに日本語と英語の両方のキーワードを含める
+	BilingualKeywords bool
+	// Jira検索クエリのキーワードの最小数（不足分は問い合わせから補う、0の場合は補わない）
+	MinKeywords int
+	// 類似度判定で重視する観点（空の場合はカスタマーサービスの観点）
+	SimilarityCriteria string
+	// 課題本文とSlackスレッドの合計がこの文字数未満の場合は類似度計算を呼ばずに0とする
+	SimilarityMinContentChars int
+	// プロンプトとレスポンスを slog.Debug で出力する（機密が含まれうるため調査時のみ有効にする）
+	DebugDump bool
+	// 優先して使うLLMのプロバイダ（azure / openai）
+	Primary string
+	// Primary の呼び出しに失敗した場合に再試行するプロバイダ（空の場合はフォールバックしない）
+	Fallback string
+}
+
+// IsAzure はAzure OpenAIを優先して使用するかを返す
+func (c OpenAIConfig) IsAzure() bool {
+	return c.Primary == LLMProviderAzure
+}
+
+// LLMのプロバイダ
+const (
+	LLMProviderAzure  = "azure"
+	LLMProviderOpenAI = "openai"
+	LLMProviderNone   = "none"
+)
+
+// 認証情報がすべて設定されているプロバイダかを返す
+func (c OpenAIConfig) hasCredentials(provider string) bool {
+	if provider == LLMProviderAzure {
+		return c.AzureEndpoint != "" && c.AzureKey != ""
+	}
+	return c.APIKey != ""
+}
+
+// LLM_PRIMARY / LLM_FALLBACK を解決し、使用するプロバイダの認証情報を検証する
+// LLM_PRIMARY が未指定の場合は AZURE_OPENAI_ENDPOINT の有無で決め、
+// LLM_FALLBACK が未指定の場合はもう一方の認証情報がそろっていればフォールバック先にする
+func (l *loader) llmProviders(c *OpenAIConfig) {
+	if c.Primary == "" {
+		c.Primary = LLMProviderOpenAI
+		if c.AzureEndpoint != "" {
+			c.Primary = LLMProviderAzure
+		}
+	}
+	if c.Primary == LLMProviderAzure {
+		c.AzureEndpoint = l.required("AZURE_OPENAI_ENDPOINT")
+		c.AzureKey = l.required("AZURE_OPENAI_KEY")
+	} else {
+		c.APIKey = l.required("OPENAI_API_KEY")
+	}
+
+	other := LLMProviderAzure
+	if c.Primary == LLMProviderAzure {
+		other = LLMProviderOpenAI
+	}
+	switch c.Fallback {
+	case "":
+		if c.hasCredentials(other) {
+			c.Fallback = other
+		}
+	case LLMProviderNone:
+		c.Fallback = ""
+	case c.Primary:
+		l.errs = append(l.errs, fmt.Errorf("LLM_FALLBACK must differ from LLM_PRIMARY: %s", c.Fallback))
+	default:
+		if !c.hasCredentials(c.Fallback) {
+			l.errs = append(l.errs, fmt.Errorf("LLM_FALLBACK=%s requires its credentials", c.Fallback))
+		}
+	}
+}
+
+// SelectionConfig は課題の選定処理の設定
+type SelectionConfig struct {
+	SlackSearchTimeout time.Duration
+	SlackFormatTimeout time.Duration
+	SimilarityTimeout  time.Duration
+	// 選定処理全体のタイムアウト（0の場合は無制限）
+	SelectTimeout time.Duration
+	// 課題ごとの処理の並列度
+	Concurrency int
+	// 類似度計算の前に課題を要点に圧縮する
+	CondenseBeforeSimilarity bool
+	// 長いSlackスレッドは解決に関する発言を優先して抽出してから使う
+	ThreadSummarize bool
+	// Slackスレッドの返信数を類似度に加味する重み（0の場合は加味しない）
+	ThreadEngagementWeight float64
+	// Embeddingで上位 Stage1TopN 件に絞ってから類似度を計算する
+	TwoStageRanking bool
+	Stage1TopN      int
+	// しきい値以上の課題が無いときに低類似度の上位候補を返す
+	FallbackLowSimilarity bool
+	// 類似度を計算する課題の最大数
+	MaxProcessIssues int
+	// 同一の問い合わせの結果をキャッシュする期間（0 の場合はキャッシュしない）
+	ResultCacheTTL time.Duration
+	// 候補全体の類似度の分布をSlackにも表示する
+	ShowDistribution bool
+	// issueごとの処理結果をNDJSONで追記するファイル（空の場合は出力しない）
+	ExportPath string
+}
+
+// HandlerConfig はSlackへの応答の設定
+type HandlerConfig struct {
+	// false の場合は途中経過を通知しない
+	VerboseSteps bool
+	// 最終結果の末尾に処理時間を表示する
+	ShowTiming bool
+	// 要約をストリーミングで生成する
+	SummaryStream         bool
+	SummaryStreamInterval time.Duration
+	// 連投された問い合わせをまとめる間隔（0 の場合は即時処理）
+	Debounce time.Duration
+	// ユーザーごとの直近の問い合わせを文脈としてプロンプトに渡す
+	ConversationMemory    bool
+	ConversationMemoryTTL time.Duration
+	// status コマンドを実行できるユーザーID
+	AdminUsers     []string
+	MessagesFile   string
+	StatusEmojiMap map[string]string
+	StatsChannel   string
+	StatsCron      string
+	// 結果を一度に投稿する件数（0の場合は全件）
+	ResultPageSize int
+	// DMの結果を共有するチャンネルID（空の場合は共有先を選択させる）
+	ShareChannel string
+	WebhookURL   string
+}
+
+// LoadConfig は環境変数から設定を読み込み、デフォルト値の適用と検証を行う
+// 検証エラーはまとめて返す
+func LoadConfig() (*Config, error) {
+	l := &loader{}
+	c := &Config{
+		Slack: SlackConfig{
+			Mode:                l.oneOf("SLACK_MODE", SlackModeSocket, SlackModeSocket, SlackModeHTTP),
+			BotToken:            l.required("SLACK_BOT_TOKEN"),
+			HTTPAddr:            stringOrDefault(os.Getenv("SLACK_HTTP_ADDR"), ":3000"),
+			UserToken:           l.required("SLACK_USER_TOKEN"),
+			WorkspaceURL:        os.Getenv("SLACK_WORKSPACE_URL"),
+			Channel:             strings.TrimPrefix(os.Getenv("SLACK_CHANNEL"), "#"),
+			ExcludeBots:         splitList(os.Getenv("SLACK_EXCLUDE_BOTS")),
+			SearchAllowChannels: splitList(os.Getenv("SLACK_SEARCH_ALLOW_CHANNELS")),
+			UserNameFormat:      os.Getenv("USER_NAME_FORMAT"),
+			AutoRespondChannels: splitList(os.Getenv("AUTO_RESPOND_CHANNELS")),
+			SearchBySummary:     l.oneOf("SLACK_SEARCH_BY_SUMMARY", "", "and", "or"),
+			SearchMaxThreads:    l.positiveInt("SLACK_SEARCH_MAX_THREADS", 5),
+			GroupByThread:       os.Getenv("SLACK_GROUP_BY_THREAD") == "true",
+			CollapseResults:     os.Getenv("SLACK_COLLAPSE_RESULTS") == "true",
+			UseChannelContext:   os.Getenv("USE_CHANNEL_CONTEXT") == "true",
+			QuietMode:           os.Getenv("QUIET_MODE") == "true",
+			QuietChannels:       splitList(os.Getenv("QUIET_CHANNELS")),
+		},
+		Jira: JiraConfig{
+			Endpoint:          l.required("JIRA_ENDPOINT"),
+			AuthType:          l.oneOf("JIRA_AUTH_TYPE", "basic", "basic", "bearer"),
+			APIToken:          l.required("JIRA_API_TOKEN"),
+			ProjectKey:        l.required("JIRA_PROJECT_KEY"),
+			SearchQuery:       os.Getenv("JIRA_SEARCH_QUERY"),
+			OrderBy:           jiraOrderBy(os.Getenv("JIRA_ORDER_BY")),
+			FetchFullComments: os.Getenv("FETCH_FULL_COMMENTS") == "true",
+			SearchMineFirst:   os.Getenv("SEARCH_MINE_FIRST") == "true",
+			JQLTemplate:       os.Getenv("JQL_TEMPLATE"),
+			APIVersion:        l.oneOf("JIRA_API_VERSION", "", "2", "3", "auto"),
+			DumpDir:           os.Getenv("JIRA_DUMP_DIR"),
+			UseRendered:       os.Getenv("JIRA_USE_RENDERED") == "true",
+			SearchDays:        l.nonNegativeInt("JIRA_SEARCH_DAYS", 0),
+			QueryCacheTTL:     l.duration("JIRA_QUERY_CACHE_TTL", 60*time.Second),
+			AllowedFuncs:      splitList(os.Getenv("JQL_ALLOWED_FUNCS")),
+		},
+		OpenAI: OpenAIConfig{
+			APIKey:                    os.Getenv("OPENAI_API_KEY"),
+			AzureEndpoint:             os.Getenv("AZURE_OPENAI_ENDPOINT"),
+			AzureKey:                  os.Getenv("AZURE_OPENAI_KEY"),
+			AzureAPIVersion:           stringOrDefault(os.Getenv("AZURE_OPENAI_API_VERSION"), "2025-01-01-preview"),
+			Model:                     os.Getenv("OPENAI_MODEL"),
+			CondenseModel:             stringOrDefault(os.Getenv("OPENAI_CONDENSE_MODEL"), os.Getenv("OPENAI_MODEL")),
+			EmbeddingModel:            stringOrDefault(os.Getenv("OPENAI_EMBEDDING_MODEL"), "text-embedding-3-small"),
+			JSONMode:                  l.oneOf("OPENAI_JSON_MODE", JSONModeAuto, JSONModeAuto, JSONModeOn, JSONModeOff),
+			BilingualKeywords:         os.Getenv("BILINGUAL_KEYWORDS") == "true",
+			MinKeywords:               l.nonNegativeInt("MIN_KEYWORDS", 2),
+			SimilarityCriteria:        os.Getenv("SIMILARITY_CRITERIA"),
+			SummaryLanguage:           l.oneOf("SUMMARY_LANGUAGE", "", "ja", "en"),
+			SummaryOverviewChars:      l.intInRange("SUMMARY_OVERVIEW_CHARS", defaultSummaryChars, minSummaryChars, maxSummaryChars),
+			SummaryResolutionChars:    l.intInRange("SUMMARY_RESOLUTION_CHARS", defaultSummaryChars, minSummaryChars, maxSummaryChars),
+			EmbeddingCache:            l.oneOf("EMBEDDING_CACHE", EmbeddingCacheMemory, EmbeddingCacheMemory, EmbeddingCacheFile),
+			EmbeddingCacheDir:         os.Getenv("EMBEDDING_CACHE_DIR"),
+			ResponseCache:             os.Getenv("LLM_RESPONSE_CACHE") == "true",
+			ResponseCacheDir:          os.Getenv("LLM_RESPONSE_CACHE_DIR"),
+			SimilarityMinContentChars: l.nonNegativeInt("SIMILARITY_MIN_CONTENT_CHARS", 20),
+			DebugDump:                 os.Getenv("OPENAI_DEBUG_DUMP") == "true",
+			Primary:                   l.oneOf("LLM_PRIMARY", "", LLMProviderAzure, LLMProviderOpenAI),
+			Fallback:                  l.oneOf("LLM_FALLBACK", "", LLMProviderAzure, LLMProviderOpenAI, LLMProviderNone),
+		},
+		Selection: SelectionConfig{
+			SlackSearchTimeout:       l.duration("SLACK_SEARCH_TIMEOUT", 30*time.Second),
+			SlackFormatTimeout:       l.duration("SLACK_FORMAT_TIMEOUT", 10*time.Second),
+			SimilarityTimeout:        l.duration("SIMILARITY_TIMEOUT", 60*time.Second),
+			SelectTimeout:            l.duration("SELECT_TIMEOUT", 0),
+			Concurrency:              l.positiveInt("MAX_CONCURRENCY", 5),
+			CondenseBeforeSimilarity: os.Getenv("CONDENSE_BEFORE_SIMILARITY") == "true",
+			ThreadSummarize:          os.Getenv("THREAD_SUMMARIZE") == "true",
+			ThreadEngagementWeight:   l.floatInRange("THREAD_ENGAGEMENT_WEIGHT", 0, 0, 1),
+			TwoStageRanking:          os.Getenv("TWO_STAGE_RANKING") == "true",
+			Stage1TopN:               l.positiveInt("STAGE1_TOP_N", 10),
+			FallbackLowSimilarity:    os.Getenv("FALLBACK_LOW_SIMILARITY") == "true",
+			MaxProcessIssues:         l.positiveInt("MAX_PROCESS_ISSUES", 30),
+			ResultCacheTTL:           l.duration("RESULT_CACHE_TTL", 0),
+			ShowDistribution:         os.Getenv("SHOW_DISTRIBUTION") == "true",
+			ExportPath:               os.Getenv("SELECT_EXPORT_PATH"),
+		},
+		Handler: HandlerConfig{
+			VerboseSteps:          os.Getenv("VERBOSE_STEPS") != "false",
+			ShowTiming:            os.Getenv("SHOW_TIMING") == "true",
+			SummaryStream:         os.Getenv("SUMMARY_STREAM") == "true",
+			SummaryStreamInterval: l.duration("SUMMARY_STREAM_INTERVAL", time.Second),
+			Debounce:              time.Duration(l.nonNegativeInt("DEBOUNCE_MS", 0)) * time.Millisecond,
+			ConversationMemory:    os.Getenv("CONVERSATION_MEMORY") == "true",
+			ConversationMemoryTTL: l.duration("CONVERSATION_MEMORY_TTL", 10*time.Minute),
+			AdminUsers:            splitList(os.Getenv("ADMIN_USERS")),
+			MessagesFile:          os.Getenv("MESSAGES_FILE"),
+			StatusEmojiMap:        l.stringMap("STATUS_EMOJI_MAP"),
+			StatsChannel:          os.Getenv("STATS_CHANNEL"),
+			ShareChannel:          os.Getenv("SHARE_CHANNEL"),
+			ResultPageSize:        l.nonNegativeInt("RESULT_PAGE_SIZE", 0),
+			StatsCron:             os.Getenv("STATS_CRON"),
+			WebhookURL:            os.Getenv("RESULT_WEBHOOK_URL"),
+		},
+	}
+
+	if c.Slack.Mode == SlackModeHTTP {
+		c.Slack.SigningSecret = l.required("SLACK_SIGNING_SECRET")
+	} else {
+		c.Slack.AppToken = l.required("SLACK_APP_TOKEN")
+	}
+	// Bearer認証ではユーザー名は不要
+	if c.Jira.AuthType == "basic" {
+		c.Jira.Username = l.required("JIRA_USERNAME")
+	}
+	if c.Jira.JQLTemplate != "" && !strings.Contains(c.Jira.JQLTemplate, "{{keywords}}") {
+		l.errs = append(l.errs, fmt.Errorf("JQL_TEMPLATE must contain {{keywords}}: %s", c.Jira.JQLTemplate))
+	}
+	if c.Jira.APIVersion == "auto" {
+		c.Jira.APIVersion = ""
+	}
+	l.llmProviders(&c.OpenAI)
+
+	if err := errors.Join(l.errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 環境変数を読み込み、検証エラーを蓄積する
+type loader struct {
+	errs []error
+}
+
+func (l *loader) required(key string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		l.errs = append(l.errs, fmt.Errorf("required environment variable not set: %s", key))
+	}
+	return v
+}
+
+// 許可された値のいずれかであることを検証する。未設定の場合は def を返す
+func (l *loader) oneOf(key, def string, allowed ...string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(v, a) {
+			return a
+		}
+	}
+	l.errs = append(l.errs, fmt.Errorf("invalid %s: %s (allowed: %s)", key, v, strings.Join(allowed, ", ")))
+	return def
+}
+
+func (l *loader) duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		l.errs = append(l.errs, fmt.Errorf("invalid %s: %s", key, v))
+		return def
+	}
+	return d
+}
+
+func (l *loader) int(key string, def int) (int, bool) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("invalid %s: %s", key, v))
+		return def, false
+	}
+	return n, true
+}
+
+func (l *loader) positiveInt(key string, def int) int {
+	n, ok := l.int(key, def)
+	if ok && n <= 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s must be positive: %d", key, n))
+		return def
+	}
+	return n
+}
+
+func (l *loader) nonNegativeInt(key string, def int) int {
+	n, ok := l.int(key, def)
+	if ok && n < 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s must not be negative: %d", key, n))
+		return def
+	}
+	return n
+}
+
+// 範囲外の値は範囲内に丸める
+func (l *loader) intInRange(key string, def, minValue, maxValue int) int {
+	n, _ := l.int(key, def)
+	return max(minValue, min(n, maxValue))
+}
+
+// 数値として解釈できない値はエラーとし、範囲外の値は範囲内に丸める
+func (l *loader) floatInRange(key string, def, minValue, maxValue float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) {
+		l.errs = append(l.errs, fmt.Errorf("invalid %s: %s", key, v))
+		return def
+	}
+	return max(minValue, min(f, maxValue))
+}
+
+// JSONオブジェクト形式の文字列マップを読み込む
+func (l *loader) stringMap(key string) map[string]string {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(v), &m); err != nil {
+		l.errs = append(l.errs, fmt.Errorf("invalid %s: %w", key, err))
+		return nil
+	}
+	return m
+}
+
+// JIRA_ORDER_BY から検索クエリに付与する並び順を求める
+// "ORDER BY" の有無はどちらでもよく、none を指定すると付与しない
+func jiraOrderBy(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return defaultJiraOrderBy
+	}
+	if strings.EqualFold(v, "none") {
+		return ""
+	}
+	if len(v) > len("order by") && strings.EqualFold(v[:len("order by")], "order by") {
+		v = strings.TrimSpace(v[len("order by"):])
+	}
+	return v
+}
+
+// カンマ区切りの値を分割する。空白と先頭の # は除去し、空の要素は除外する
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimPrefix(strings.TrimSpace(item), "#"); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func stringOrDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
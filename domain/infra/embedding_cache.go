@@ -0,0 +1,167 @@
+package infra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+	"github.com/pyama86/jipcy/config"
+)
+
+// ヒット率をInfoログに出力する間隔（参照回数）
+const embeddingCacheLogInterval = 100
+
+// embeddingStore はembeddingの保存先
+type embeddingStore interface {
+	get(key string) ([]float64, bool)
+	set(key string, embedding []float64) error
+}
+
+// EmbeddingCache は課題本文のembeddingを内容のハッシュをキーにキャッシュする
+type EmbeddingCache struct {
+	store  embeddingStore
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewEmbeddingCache は EMBEDDING_CACHE (memory/file) に応じたキャッシュを生成する
+// file の場合は EMBEDDING_CACHE_DIR に保存し、起動をまたいで再利用する
+func NewEmbeddingCache(cfg config.OpenAIConfig) (*EmbeddingCache, error) {
+	switch cfg.EmbeddingCache {
+	case config.EmbeddingCacheMemory:
+		cache := ttlcache.New(ttlcache.WithTTL[string, []float64](time.Hour * 24))
+		go cache.Start()
+		return &EmbeddingCache{store: &memoryEmbeddingStore{cache: cache}}, nil
+	case config.EmbeddingCacheFile:
+		dir := cfg.EmbeddingCacheDir
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "jipcy-embeddings")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create embedding cache dir: %w", err)
+		}
+		return &EmbeddingCache{store: &fileEmbeddingStore{dir: dir}}, nil
+	default:
+		return nil, fmt.Errorf("invalid EMBEDDING_CACHE: %s", cfg.EmbeddingCache)
+	}
+}
+
+// embeddingCacheKey はモデル名と本文からキャッシュのキーを生成する
+func embeddingCacheKey(model, text string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *EmbeddingCache) Get(key string) ([]float64, bool) {
+	embedding, ok := c.store.get(key)
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	c.logHitRate()
+	return embedding, ok
+}
+
+func (c *EmbeddingCache) Set(key string, embedding []float64) {
+	if err := c.store.set(key, embedding); err != nil {
+		slog.Warn("Failed to store embedding cache", slog.String("key", key), slog.Any("err", err))
+	}
+}
+
+// HitRate はこれまでの参照に対するキャッシュヒット率を返す
+func (c *EmbeddingCache) HitRate() float64 {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+func (c *EmbeddingCache) logHitRate() {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	attrs := []any{
+		slog.Int64("hits", hits),
+		slog.Int64("misses", misses),
+		slog.Float64("hit_rate", c.HitRate()),
+	}
+	if (hits+misses)%embeddingCacheLogInterval == 0 {
+		slog.Info("Embedding cache stats", attrs...)
+		return
+	}
+	slog.Debug("Embedding cache stats", attrs...)
+}
+
+type memoryEmbeddingStore struct {
+	cache *ttlcache.Cache[string, []float64]
+}
+
+func (s *memoryEmbeddingStore) get(key string) ([]float64, bool) {
+	item := s.cache.Get(key)
+	if item == nil {
+		return nil, false
+	}
+	return item.Value(), true
+}
+
+func (s *memoryEmbeddingStore) set(key string, embedding []float64) error {
+	s.cache.Set(key, embedding, ttlcache.DefaultTTL)
+	return nil
+}
+
+// キーごとにJSONファイルとして保存する
+type fileEmbeddingStore struct {
+	dir string
+}
+
+func (s *fileEmbeddingStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+func (s *fileEmbeddingStore) get(key string) ([]float64, bool) {
+	b, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var embedding []float64
+	if err := json.Unmarshal(b, &embedding); err != nil {
+		slog.Warn("Broken embedding cache file, ignored", slog.String("path", s.path(key)), slog.Any("err", err))
+		return nil, false
+	}
+	return embedding, true
+}
+
+func (s *fileEmbeddingStore) set(key string, embedding []float64) error {
+	b, err := json.Marshal(embedding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal embedding: %w", err)
+	}
+	if err := writeFileAtomic(s.dir, key+".json", b); err != nil {
+		return fmt.Errorf("failed to write embedding cache file: %w", err)
+	}
+	return nil
+}
+
+// 書き込み途中のファイルを読まないよう一時ファイルに書いてからリネームする
+func writeFileAtomic(dir, name string, b []byte) error {
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
@@ -1,15 +1,26 @@
 package infra
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/url"
-	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/andygrunwald/go-jira"
+	ttlcache "github.com/jellydator/ttlcache/v3"
+	"github.com/pyama86/jipcy/config"
 )
 
 // ADF (Atlassian Document Format) 構造体
+// REST API v2 (Jira Server/Data Center) では本文がwiki記法の文字列で返るため Wiki に保持する
 type ADFContent struct {
 	Type    string `json:"type"`
 	Version int    `json:"version,omitempty"`
@@ -20,10 +31,23 @@ type ADFContent struct {
 			Text string `json:"text,omitempty"`
 		} `json:"content,omitempty"`
 	} `json:"content,omitempty"`
+	Wiki string `json:"-"`
+}
+
+// ADFのオブジェクトとwiki記法の文字列のどちらでも受け付ける
+func (a *ADFContent) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &a.Wiki)
+	}
+	type adf ADFContent
+	return json.Unmarshal(data, (*adf)(a))
 }
 
 // ADFからプレーンテキストを抽出する関数
 func extractTextFromADF(adf ADFContent) string {
+	if adf.Wiki != "" {
+		return extractTextFromWiki(adf.Wiki)
+	}
 	var texts []string
 	for _, content := range adf.Content {
 		for _, innerContent := range content.Content {
@@ -35,6 +59,12 @@ func extractTextFromADF(adf ADFContent) string {
 	return strings.Join(texts, " ")
 }
 
+// 添付ファイルのメタ情報
+type Attachment struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
 // Jira API v3と互換性のあるカスタムIssue構造体
 type Issue struct {
 	ID     string `json:"id"`
@@ -42,31 +72,167 @@ type Issue struct {
 	Fields struct {
 		Summary     string     `json:"summary"`
 		Description ADFContent `json:"description"`
-		Comment     struct {
-			Comments []struct {
-				Body    ADFContent `json:"body"`
-				Created string     `json:"created"`
-				Author  struct {
-					DisplayName string `json:"displayName"`
-				} `json:"author"`
-			} `json:"comments"`
-			MaxResults int `json:"maxResults"`
-			StartAt    int `json:"startAt"`
-			Total      int `json:"total"`
+		Reporter    *struct {
+			DisplayName string `json:"displayName"`
+		} `json:"reporter"`
+		Assignee *struct {
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Priority *struct {
+			Name string `json:"name"`
+		} `json:"priority"`
+		Watches struct {
+			WatchCount int `json:"watchCount"`
+		} `json:"watches"`
+		Status *struct {
+			Name           string `json:"name"`
+			StatusCategory struct {
+				Key string `json:"key"`
+			} `json:"statusCategory"`
+		} `json:"status"`
+		Resolution *struct {
+			Name string `json:"name"`
+		} `json:"resolution"`
+		Attachment []Attachment `json:"attachment"`
+		Updated    string       `json:"updated"`
+		// 親課題（サブタスク・Epic配下の課題のみ）
+		Parent *struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Summary string `json:"summary"`
+			} `json:"fields"`
+		} `json:"parent"`
+		Comment struct {
+			Comments   []Comment `json:"comments"`
+			MaxResults int       `json:"maxResults"`
+			StartAt    int       `json:"startAt"`
+			Total      int       `json:"total"`
 		} `json:"comment"`
 	} `json:"fields"`
+	// expand=renderedFields 指定時のみ返るレンダリング済みHTML
+	RenderedFields *renderedFields `json:"renderedFields,omitempty"`
+}
+
+// 課題のコメント
+type Comment struct {
+	Body ADFContent `json:"body"`
+	// JIRA_USE_RENDERED 有効時のレンダリング済みHTML
+	RenderedBody string `json:"renderedBody,omitempty"`
+	Created      string `json:"created"`
+	Author       struct {
+		DisplayName string `json:"displayName"`
+	} `json:"author"`
+}
+
+// スプリント情報
+type Sprint struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
 }
 
 // プレーンテキストとしてDescriptionを取得
 func (i *Issue) GetDescription() string {
+	if i.RenderedFields != nil && i.RenderedFields.Description != "" {
+		return htmlToText(i.RenderedFields.Description)
+	}
 	return extractTextFromADF(i.Fields.Description)
 }
 
+// 値が null・空の項目の表示
+const unsetFieldValue = "未設定"
+
+func orUnsetField(v string) string {
+	if v == "" {
+		return unsetFieldValue
+	}
+	return v
+}
+
+// GetAssigneeName は担当者の表示名を返す。未割り当ての場合は「未設定」を返す
+func (i *Issue) GetAssigneeName() string {
+	if i.Fields.Assignee == nil {
+		return unsetFieldValue
+	}
+	return orUnsetField(i.Fields.Assignee.DisplayName)
+}
+
+// GetPriorityName は優先度の名前を返す。優先度が無い場合は「未設定」を返す
+func (i *Issue) GetPriorityName() string {
+	if i.Fields.Priority == nil {
+		return unsetFieldValue
+	}
+	return orUnsetField(i.Fields.Priority.Name)
+}
+
+// GetReporterNameOrUnset は報告者の表示名を返す。報告者が無い場合は「未設定」を返す
+func (i *Issue) GetReporterNameOrUnset() string {
+	return orUnsetField(i.GetReporterName())
+}
+
+// GetStatusNameOrUnset はステータス名を返す。ステータスが無い場合は「未設定」を返す
+func (i *Issue) GetStatusNameOrUnset() string {
+	return orUnsetField(i.GetStatusName())
+}
+
+// 報告者の表示名を取得
+func (i *Issue) GetReporterName() string {
+	if i.Fields.Reporter == nil {
+		return ""
+	}
+	return i.Fields.Reporter.DisplayName
+}
+
+// ステータス名を取得
+func (i *Issue) GetStatusName() string {
+	if i.Fields.Status == nil {
+		return ""
+	}
+	return i.Fields.Status.Name
+}
+
+// IsResolved は解決状態を resolution とステータスカテゴリ（done/indeterminate/new）から判定する
+// resolution が設定されているか、ステータスカテゴリが done の場合に解決済みとみなす
+func (i *Issue) IsResolved() bool {
+	if i.Fields.Resolution != nil && i.Fields.Resolution.Name != "" {
+		return true
+	}
+	return i.Fields.Status != nil && i.Fields.Status.StatusCategory.Key == "done"
+}
+
+// Jiraの日時フォーマット
+const jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// 更新日時を取得。取得・解析できない場合はゼロ値を返す
+func (i *Issue) GetUpdated() time.Time {
+	t, err := time.Parse(jiraTimeLayout, i.Fields.Updated)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// 解決状態の表示名を取得
+func (i *Issue) GetResolutionName() string {
+	if i.Fields.Resolution == nil {
+		return ""
+	}
+	return i.Fields.Resolution.Name
+}
+
+// 親課題のキーとサマリを取得する。親が無い場合は ok が false
+func (i *Issue) GetParent() (key, summary string, ok bool) {
+	if i.Fields.Parent == nil || i.Fields.Parent.Key == "" {
+		return "", "", false
+	}
+	return i.Fields.Parent.Key, i.Fields.Parent.Fields.Summary, true
+}
+
 // プレーンテキストとしてコメントを取得
 func (i *Issue) GetComments() []string {
 	var comments []string
 	for _, comment := range i.Fields.Comment.Comments {
-		commentText := extractTextFromADF(comment.Body)
+		commentText := comment.text()
 		if commentText != "" {
 			comments = append(comments, fmt.Sprintf("作成者: %s\n作成日時: %s\n内容: %s",
 				comment.Author.DisplayName, comment.Created, commentText))
@@ -75,68 +241,398 @@ func (i *Issue) GetComments() []string {
 	return comments
 }
 
+// 1回の問い合わせで取得する課題の最大数
+const MaxSearchResults = 30
+
+var orderByPattern = regexp.MustCompile(`(?i)\border\s+by\b`)
+
 type Jira struct {
 	client *jira.Client
+	// 使用するREST APIのバージョン（Cloud は "3"、Server/Data Center は "2"）
+	apiVersion string
+	// 検索対象のプロジェクトキー
+	projectKeys []string
+	// 検索クエリに付与する並び順（空の場合は付与しない）
+	orderBy string
+	// キーワードを差し込むJQLのテンプレート（空の場合は使用しない）
+	jqlTemplate string
+	// true の場合は検索結果に含まれないコメントを追加で取得する
+	fetchFullComments bool
+	// JQLの検証に使う有効なフィールド名
+	fieldCache *ttlcache.Cache[string, map[string]bool]
+	// 検索レスポンスの書き出し先（JIRA_DUMP_DIR 未設定の場合は何もしない）
+	dumper *jiraDumper
+	// メールアドレスごとのJiraのアカウントID
+	accountCache *ttlcache.Cache[string, string]
+	// true の場合は本文・コメントをレンダリング済みHTMLから取得する
+	useRendered bool
+	// 検索対象とする更新日の期間（日数、0の場合は制限しない）
+	searchDays int
+	// レスポンスヘッダから得たレートリミットの状態
+	rateLimit *rateLimitTransport
+	// 同一JQLの検索結果のキャッシュ
+	queryCache *jiraQueryCache
+	// 生成されたJQLで使用を禁止する関数名（小文字）
+	disallowedFuncs map[string]bool
+	// Agile APIが404を返した場合に以降のスプリント情報の取得を省略する
+	agileUnavailable atomic.Bool
 }
 
-func NewJira() (*Jira, error) {
-	tp := jira.BasicAuthTransport{
-		Username: os.Getenv("JIRA_USERNAME"),
-		Password: os.Getenv("JIRA_API_TOKEN"),
+func NewJira(cfg *config.Config) (*Jira, error) {
+	rateLimit := newRateLimitTransport()
+	httpClient, err := newJiraHTTPClient(cfg.Jira, rateLimit)
+	if err != nil {
+		return nil, err
 	}
 
-	jiraClient, err := jira.NewClient(tp.Client(), os.Getenv("JIRA_ENDPOINT"))
+	jiraClient, err := jira.NewClient(httpClient, cfg.Jira.Endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Jira client: %w", err)
 	}
-	return &Jira{
-		client: jiraClient,
-	}, nil
+	j := &Jira{
+		client:            jiraClient,
+		apiVersion:        cfg.Jira.APIVersion,
+		projectKeys:       cfg.Jira.ProjectKeys(),
+		orderBy:           cfg.Jira.OrderBy,
+		jqlTemplate:       cfg.Jira.JQLTemplate,
+		fetchFullComments: cfg.Jira.FetchFullComments,
+		fieldCache:        newFieldCache(),
+		dumper:            newJiraDumper(cfg.Jira.DumpDir),
+		accountCache:      ttlcache.New(ttlcache.WithTTL[string, string](time.Hour * 24)),
+		useRendered:       cfg.Jira.UseRendered,
+		searchDays:        cfg.Jira.SearchDays,
+		rateLimit:         rateLimit,
+		queryCache:        newJiraQueryCache(cfg.Jira.QueryCacheTTL),
+		disallowedFuncs:   disallowedJQLFunctions(cfg.Jira.AllowedFuncs),
+	}
+	go j.accountCache.Start()
+	if j.apiVersion == "" {
+		j.apiVersion = j.detectAPIVersion()
+	}
+	slog.Info("Jira API version", slog.String("version", j.apiVersion))
+	return j, nil
 }
 
-func (h *Jira) FetchIssues(query string) ([]Issue, error) {
-	// 新しいv3 APIエンドポイントを使用
+// JQLに並び順を付与する。既にORDER BYが含まれている場合はそのまま返す
+func (h *Jira) withOrderBy(query string) string {
+	if h.orderBy == "" || orderByPattern.MatchString(query) {
+		return query
+	}
+	return fmt.Sprintf("%s ORDER BY %s", strings.TrimSpace(query), h.orderBy)
+}
+
+// JIRA_AUTH_TYPE に応じた認証付きHTTPクライアントを生成する
+// 未指定時は従来通りBasic認証を使用する
+// transport は認証ヘッダを付与した後のリクエストを送る下位のトランスポート
+func newJiraHTTPClient(cfg config.JiraConfig, transport http.RoundTripper) (*http.Client, error) {
+	switch cfg.AuthType {
+	case "basic":
+		tp := jira.BasicAuthTransport{
+			Username:  cfg.Username,
+			Password:  cfg.APIToken,
+			Transport: transport,
+		}
+		return tp.Client(), nil
+	case "bearer":
+		tp := jira.BearerAuthTransport{
+			Token:     cfg.APIToken,
+			Transport: transport,
+		}
+		return tp.Client(), nil
+	default:
+		return nil, fmt.Errorf("unsupported JIRA_AUTH_TYPE: %s", cfg.AuthType)
+	}
+}
+
+// FetchIssues はJQLで課題を検索し、取得した課題と総ヒット件数を返す
+// 結果が打ち切られていて総件数を取得できなかった場合は TotalUnknown を返す
+// 同一JQLの結果は JIRA_QUERY_CACHE_TTL の間キャッシュから返す
+func (h *Jira) FetchIssues(ctx context.Context, query string) ([]Issue, int, error) {
+	query = h.withOrderBy(query)
+	if issues, total, ok := h.queryCache.get(query); ok {
+		slog.InfoContext(ctx, "Jira query cache hit", slog.String("jql", query), slog.Int("fetched", len(issues)), slog.Int("total", total))
+		return issues, total, nil
+	}
+	issues, total, err := h.searchIssues(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	h.queryCache.set(query, issues, total)
+	return issues, total, nil
+}
+
+// JQLで課題を検索する。コメントの補完まで済ませた結果を返す
+func (h *Jira) searchIssues(ctx context.Context, query string) ([]Issue, int, error) {
 	params := url.Values{}
 	params.Add("jql", query)
-	params.Add("fields", "summary,description,comment")
-	params.Add("maxResults", "30")
+	params.Add("fields", "summary,description,comment,reporter,assignee,priority,watches,status,resolution,attachment,updated,parent")
+	params.Add("maxResults", strconv.Itoa(MaxSearchResults))
+	if h.useRendered {
+		params.Add("expand", "renderedFields")
+	}
 
-	req, err := h.client.NewRequest("GET", "rest/api/3/search/jql", nil)
+	req, err := h.client.NewRequestWithContext(ctx, "GET", h.searchPath(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// クエリパラメーターを設定
 	req.URL.RawQuery = params.Encode()
 
-	// Jira API v3のレスポンス構造に基づいた構造体を定義
+	// v3 の search/jql と v2 の search のレスポンスに共通する構造体
 	type SearchResult struct {
 		Issues []Issue `json:"issues"`
 		IsLast bool    `json:"isLast"`
 		Total  int     `json:"total,omitempty"`
 	}
 
-	var result SearchResult
-	_, err = h.client.Do(req, &result)
+	// 調査用に書き出せるよう、rawのレスポンスを受け取ってからデコードする
+	var raw json.RawMessage
+	resp, err := h.client.Do(req, &raw)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search Jira API: %w", err)
+		return nil, 0, fmt.Errorf("failed to search Jira API: %w", newJQLError(query, resp, err))
+	}
+	h.dumper.dump(ctx, query, raw)
+
+	var result SearchResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode Jira search response: %w", err)
+	}
+
+	// v2 の検索APIは isLast を返さないため総件数から判定する
+	if h.apiVersion == apiVersionServer {
+		result.IsLast = len(result.Issues) >= result.Total
+	}
+
+	total := result.Total
+	if h.apiVersion != apiVersionServer && !result.IsLast {
+		count, err := h.approximateCount(ctx, query)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch approximate count of Jira issues", slog.String("jql", query), slog.Any("err", err))
+			total = TotalUnknown
+		} else {
+			total = count
+		}
+	}
+	if total != TotalUnknown && total < len(result.Issues) {
+		total = len(result.Issues)
+	}
+	slog.InfoContext(ctx, "Jira search result",
+		slog.String("jql", query),
+		slog.Int("fetched", len(result.Issues)),
+		slog.Int("total", total),
+		slog.Bool("is_last", result.IsLast))
+	if !result.IsLast {
+		slog.WarnContext(ctx, "Jira search result was truncated", slog.String("jql", query), slog.Int("fetched", len(result.Issues)))
 	}
 
 	// 空の結果は正常なケースとして扱う（エラーにしない）
 	if len(result.Issues) == 0 {
+		return []Issue{}, total, nil
+	}
+
+	if h.useRendered {
+		applyRenderedComments(result.Issues)
+	}
+	if h.fetchFullComments {
+		h.completeComments(ctx, result.Issues)
+	}
+	return result.Issues, total, nil
+}
+
+// 検索結果のコメントが総数に満たない課題のみ、全コメントを取得して補完する
+// 取得に失敗した場合は検索結果のコメントのまま続行する
+func (h *Jira) completeComments(ctx context.Context, issues []Issue) {
+	for i := range issues {
+		comment := &issues[i].Fields.Comment
+		if len(comment.Comments) >= comment.Total {
+			continue
+		}
+		comments, err := h.FetchAllComments(ctx, issues[i].Key)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch all comments", slog.String("issue_key", issues[i].Key), slog.Any("err", err))
+			continue
+		}
+		slog.DebugContext(ctx, "Fetched all comments",
+			slog.String("issue_key", issues[i].Key),
+			slog.Int("before", len(comment.Comments)),
+			slog.Int("after", len(comments)))
+		comment.Comments = comments
+		comment.Total = len(comments)
+	}
+}
+
+// 1回のリクエストで取得するコメント数
+const commentsPageSize = 100
+
+// FetchAllComments は課題のコメントをページングして全件取得する
+func (h *Jira) FetchAllComments(ctx context.Context, key string) ([]Comment, error) {
+	var comments []Comment
+	for {
+		req, err := h.client.NewRequestWithContext(ctx, "GET", h.apiPath("issue/%s/comment", url.PathEscape(key)), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		params := url.Values{
+			"startAt":    {strconv.Itoa(len(comments))},
+			"maxResults": {strconv.Itoa(commentsPageSize)},
+		}
+		if h.useRendered {
+			params.Add("expand", "renderedBody")
+		}
+		req.URL.RawQuery = params.Encode()
+
+		var page struct {
+			Comments []Comment `json:"comments"`
+			Total    int       `json:"total"`
+		}
+		if _, err := h.client.Do(req, &page); err != nil {
+			return nil, fmt.Errorf("failed to fetch comments: %w", err)
+		}
+		comments = append(comments, page.Comments...)
+		if len(page.Comments) == 0 || len(comments) >= page.Total {
+			return comments, nil
+		}
+	}
+}
+
+// FetchSprintInfo は課題が属するスプリントの名前と状態を取得する
+// Agileを利用していない環境などで取得できない場合はエラーにせず空で返す
+func (h *Jira) FetchSprintInfo(ctx context.Context, key string) ([]Sprint, error) {
+	if h.agileUnavailable.Load() {
+		return []Sprint{}, nil
+	}
+	req, err := h.client.NewRequestWithContext(ctx, "GET", fmt.Sprintf("rest/agile/1.0/issue/%s", url.PathEscape(key)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.URL.RawQuery = url.Values{"fields": {"sprint,closedSprints"}}.Encode()
+
+	var result struct {
+		Fields struct {
+			Sprint        *Sprint  `json:"sprint"`
+			ClosedSprints []Sprint `json:"closedSprints"`
+		} `json:"fields"`
+	}
+	resp, err := h.client.Do(req, &result)
+	if err != nil {
+		// Agile APIが無い環境では以降の呼び出しも失敗するため問い合わせない
+		if resp != nil && resp.StatusCode == http.StatusNotFound && !h.agileUnavailable.Swap(true) {
+			slog.InfoContext(ctx, "Agile API is not available, skip fetching sprint info", slog.String("issue_key", key))
+		}
+		slog.DebugContext(ctx, "Sprint info is not available", slog.String("issue_key", key), slog.Any("err", err))
+		return []Sprint{}, nil
+	}
+
+	var sprints []Sprint
+	if result.Fields.Sprint != nil {
+		sprints = append(sprints, *result.Fields.Sprint)
+	}
+	sprints = append(sprints, result.Fields.ClosedSprints...)
+	return sprints, nil
+}
+
+// FetchIssuesByKeys は課題キーを指定して課題を直接取得する
+// 存在しない・閲覧できないキーが1つでもあるとJQL全体が拒否されるため、その場合はキーごとに取得し直して取得できた課題を返す
+func (h *Jira) FetchIssuesByKeys(ctx context.Context, keys []string) ([]Issue, error) {
+	if len(keys) == 0 {
 		return []Issue{}, nil
 	}
+	issues, _, err := h.FetchIssues(ctx, fmt.Sprintf("key in (%s)", strings.Join(keys, ", ")))
+	var jqlErr *JQLError
+	if err == nil || len(keys) == 1 || !errors.As(err, &jqlErr) {
+		return issues, err
+	}
 
-	return result.Issues, nil
+	slog.WarnContext(ctx, "Failed to fetch issues by keys at once, retry one by one", slog.Any("keys", keys), slog.Any("err", err))
+	issues = []Issue{}
+	for _, key := range keys {
+		found, _, err := h.FetchIssues(ctx, fmt.Sprintf("key = %s", key))
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch issue by key", slog.String("issue_key", key), slog.Any("err", err))
+			continue
+		}
+		issues = append(issues, found...)
+	}
+	return issues, nil
 }
 
+// FetchIssuesByQueries は複数のJQLで検索し、結果を重複排除して和集合にする
+// 件数は MaxSearchResults までに制限し、総件数は各クエリの総件数の最大値とする
+// 総件数を取得できなかったクエリがあり、他のクエリの総件数も取得件数以下の場合は TotalUnknown を返す
+// 全てのクエリが失敗した場合のみエラーを返す
+func (h *Jira) FetchIssuesByQueries(ctx context.Context, queries []string) ([]Issue, int, error) {
+	var merged []Issue
+	var total int
+	unknown := false
+	var lastErr error
+	succeeded := 0
+	seen := make(map[string]bool)
+	for _, query := range queries {
+		query = h.withSearchPeriod(query)
+		issues, t, err := h.FetchIssues(ctx, query)
+		if err != nil {
+			slog.WarnContext(ctx, "Jira query failed", slog.String("jql", query), slog.Any("err", err))
+			lastErr = err
+			continue
+		}
+		succeeded++
+		if t == TotalUnknown {
+			unknown = true
+		} else if t > total {
+			total = t
+		}
+
+		added := 0
+		for _, issue := range issues {
+			if seen[issue.Key] || len(merged) >= MaxSearchResults {
+				continue
+			}
+			seen[issue.Key] = true
+			merged = append(merged, issue)
+			added++
+		}
+		slog.InfoContext(ctx, "Jira query hit",
+			slog.String("jql", query),
+			slog.Int("hits", len(issues)),
+			slog.Int("added", added))
+	}
+	if succeeded == 0 {
+		return nil, 0, lastErr
+	}
+	if total <= len(merged) && unknown {
+		return merged, TotalUnknown, nil
+	}
+	if total < len(merged) {
+		total = len(merged)
+	}
+	return merged, total, nil
+}
+
+// FetchUser はメールアドレスでJiraのユーザーを検索する
 func (h *Jira) FetchUser(email string) (*jira.User, error) {
-	user, _, err := h.client.User.Find(email)
+	// Cloud は query、Server/Data Center は username でメールアドレスを検索する
+	params := url.Values{}
+	if h.apiVersion == apiVersionServer {
+		params.Set("username", email)
+	} else {
+		params.Set("query", email)
+	}
+	req, err := h.client.NewRequest("GET", h.apiPath("user/search"), nil)
 	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.URL.RawQuery = params.Encode()
+
+	var users []jira.User
+	if _, err := h.client.Do(req, &users); err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("user not found: %s", email)
+	}
 
-	return &user[0], nil
+	return &users[0], nil
 }
 
 // project ID を取得
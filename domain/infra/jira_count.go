@@ -0,0 +1,29 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TotalUnknown は総件数を取得できず、取得件数より多いことだけが分かっている場合の総件数
+const TotalUnknown = -1
+
+// v3 の search/jql は total を返さないため、結果が打ち切られている場合は概算件数のAPIで総件数を求める
+// 件数は並び順に依存しないため ORDER BY を除いて問い合わせる
+func (h *Jira) approximateCount(ctx context.Context, query string) (int, error) {
+	if loc := orderByPattern.FindStringIndex(query); loc != nil {
+		query = strings.TrimSpace(query[:loc[0]])
+	}
+	req, err := h.client.NewRequestWithContext(ctx, "POST", h.apiPath("search/approximate-count"), map[string]string{"jql": query})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	var result struct {
+		Count int `json:"count"`
+	}
+	if _, err := h.client.Do(req, &result); err != nil {
+		return 0, fmt.Errorf("failed to fetch approximate count: %w", err)
+	}
+	return result.Count, nil
+}
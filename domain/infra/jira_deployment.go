@@ -0,0 +1,71 @@
+package infra
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Jiraの種類ごとに使用するREST APIのバージョン
+const (
+	apiVersionCloud  = "3"
+	apiVersionServer = "2"
+)
+
+// detectAPIVersion はserverInfoの deploymentType からCloud/Serverを判定し、使用するAPIバージョンを返す
+// serverInfo は Server/Data Center にv3が無いためv2で問い合わせる
+// 判定できない場合は従来通りCloud(v3)として扱う
+func (h *Jira) detectAPIVersion() string {
+	req, err := h.client.NewRequest("GET", "rest/api/2/serverInfo", nil)
+	if err != nil {
+		slog.Warn("Failed to create serverInfo request, assume Jira Cloud", slog.Any("err", err))
+		return apiVersionCloud
+	}
+	var info struct {
+		DeploymentType string `json:"deploymentType"`
+		Version        string `json:"version"`
+	}
+	if _, err := h.client.Do(req, &info); err != nil {
+		slog.Warn("Failed to fetch Jira serverInfo, assume Jira Cloud", slog.Any("err", err))
+		return apiVersionCloud
+	}
+	slog.Info("Jira deployment detected",
+		slog.String("deployment_type", info.DeploymentType),
+		slog.String("version", info.Version))
+	if strings.EqualFold(info.DeploymentType, "Cloud") {
+		return apiVersionCloud
+	}
+	return apiVersionServer
+}
+
+// apiPath はAPIバージョンに応じたREST APIのパスを返す
+func (h *Jira) apiPath(format string, args ...any) string {
+	return fmt.Sprintf("rest/api/%s/", h.apiVersion) + fmt.Sprintf(format, args...)
+}
+
+// searchPath はJQL検索のパスを返す
+// Cloud は廃止予定の search ではなく search/jql を使用する
+func (h *Jira) searchPath() string {
+	if h.apiVersion == apiVersionServer {
+		return h.apiPath("search")
+	}
+	return h.apiPath("search/jql")
+}
+
+var (
+	// {code:java} や {noformat} などのマクロ
+	wikiMacroPattern = regexp.MustCompile(`\{(code|noformat|quote|panel|color)(:[^}]*)?\}`)
+	// h1. などの見出し
+	wikiHeadingPattern = regexp.MustCompile(`(?m)^h[1-6]\.\s*`)
+	// [表示名|URL] 形式のリンク
+	wikiLinkPattern = regexp.MustCompile(`\[([^|\]]+)\|([^\]]+)\]`)
+)
+
+// wiki記法の本文から装飾を除いたプレーンテキストを抽出する
+func extractTextFromWiki(wiki string) string {
+	text := wikiMacroPattern.ReplaceAllString(wiki, "")
+	text = wikiHeadingPattern.ReplaceAllString(text, "")
+	text = wikiLinkPattern.ReplaceAllString(text, "$1 ($2)")
+	return strings.Join(strings.Fields(text), " ")
+}
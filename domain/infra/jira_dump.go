@@ -0,0 +1,72 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// 書き込み待ちのレスポンス数の上限。超えた分は破棄する
+const jiraDumpBuffer = 32
+
+// 検索レスポンスのrawJSONをファイルに書き出す
+// 書き出しは非同期・ベストエフォートで、失敗しても検索処理には影響させない
+type jiraDumper struct {
+	dir     string
+	dumps   chan jiraDump
+	counter atomic.Uint64
+}
+
+// 1回の検索分の書き出し内容
+type jiraDump struct {
+	Time     time.Time       `json:"time"`
+	JQL      string          `json:"jql"`
+	Response json.RawMessage `json:"response"`
+}
+
+func newJiraDumper(dir string) *jiraDumper {
+	if dir == "" {
+		return nil
+	}
+	d := &jiraDumper{dir: dir, dumps: make(chan jiraDump, jiraDumpBuffer)}
+	go d.run()
+	return d
+}
+
+func (d *jiraDumper) run() {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		slog.Error("Failed to create Jira dump directory", slog.String("dir", d.dir), slog.Any("err", err))
+	}
+	for dump := range d.dumps {
+		if err := d.write(dump); err != nil {
+			slog.Error("Failed to dump Jira search response", slog.String("dir", d.dir), slog.Any("err", err))
+		}
+	}
+}
+
+// 同じ時刻のファイル名が衝突しないよう連番を付ける
+func (d *jiraDumper) write(dump jiraDump) error {
+	body, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("search-%s-%d.json", dump.Time.Format("20060102T150405.000000000"), d.counter.Add(1))
+	return os.WriteFile(filepath.Join(d.dir, name), body, 0o644)
+}
+
+// dump はメイン処理をブロックしないようにレスポンスを書き込み待ちに積む
+func (d *jiraDumper) dump(ctx context.Context, jql string, raw json.RawMessage) {
+	if d == nil {
+		return
+	}
+	select {
+	case d.dumps <- jiraDump{Time: time.Now(), JQL: jql, Response: raw}:
+	default:
+		slog.WarnContext(ctx, "Jira dump buffer is full, response dropped", slog.String("jql", jql))
+	}
+}
@@ -0,0 +1,33 @@
+package infra
+
+import (
+	"fmt"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+)
+
+// UserAccountID はメールアドレスからJiraのユーザーを引き、JQLで指定するためのIDを返す
+// Cloud は accountId、Server/Data Center はユーザー名を使う
+func (h *Jira) UserAccountID(email string) (string, error) {
+	if item := h.accountCache.Get(email); item != nil {
+		return item.Value(), nil
+	}
+	user, err := h.FetchUser(email)
+	if err != nil {
+		return "", err
+	}
+	id := user.AccountID
+	if id == "" {
+		id = user.Name
+	}
+	if id == "" {
+		return "", fmt.Errorf("user has no account id: %s", email)
+	}
+	h.accountCache.Set(email, id, ttlcache.DefaultTTL)
+	return id, nil
+}
+
+// WithInvolvedUser はJQLに担当者または報告者が指定したユーザーである条件を付与する
+func WithInvolvedUser(query, accountID string) string {
+	return andCondition(query, fmt.Sprintf(`(assignee = "%[1]s" OR reporter = "%[1]s")`, accountID))
+}
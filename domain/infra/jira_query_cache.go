@@ -0,0 +1,57 @@
+package infra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+)
+
+// 同一JQLの検索結果（全ページ分をまとめて保持する）
+type cachedSearch struct {
+	issues []Issue
+	total  int
+}
+
+// JQLの検索結果を短時間キャッシュする。ttl が0の場合はキャッシュしない
+type jiraQueryCache struct {
+	cache *ttlcache.Cache[string, cachedSearch]
+}
+
+func newJiraQueryCache(ttl time.Duration) *jiraQueryCache {
+	if ttl == 0 {
+		return &jiraQueryCache{}
+	}
+	c := &jiraQueryCache{
+		cache: ttlcache.New(ttlcache.WithTTL[string, cachedSearch](ttl)),
+	}
+	go c.cache.Start()
+	return c
+}
+
+// 並び順を付与した後の最終的なJQLのハッシュをキーにする
+func jiraQueryCacheKey(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// 呼び出し側で並び替え・絞り込みをしてもキャッシュに影響しないようスライスを複製して返す
+func (c *jiraQueryCache) get(query string) ([]Issue, int, bool) {
+	if c.cache == nil {
+		return nil, 0, false
+	}
+	item := c.cache.Get(jiraQueryCacheKey(query))
+	if item == nil {
+		return nil, 0, false
+	}
+	v := item.Value()
+	return append([]Issue{}, v.issues...), v.total, true
+}
+
+func (c *jiraQueryCache) set(query string, issues []Issue, total int) {
+	if c.cache == nil {
+		return
+	}
+	c.cache.Set(jiraQueryCacheKey(query), cachedSearch{issues: append([]Issue{}, issues...), total: total}, ttlcache.DefaultTTL)
+}
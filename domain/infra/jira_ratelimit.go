@@ -0,0 +1,154 @@
+package infra
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// 残量が少ないときのリクエストの最小間隔
+	jiraThrottleInterval = time.Second
+	// 残量がこの割合以下になったら間隔を空ける
+	jiraThrottleRatio = 0.1
+)
+
+// RateLimitInfo はJiraのレスポンスヘッダから得たレートリミットの状態
+type RateLimitInfo struct {
+	Limit     int
+	Remaining int
+	// 残量が回復する時刻（ヘッダが無い場合はゼロ値）
+	Reset time.Time
+	// Jiraが上限に近いと判断している場合は true
+	NearLimit bool
+	UpdatedAt time.Time
+}
+
+// 上限に近く、呼び出し間隔を空けるべきかを返す
+func (i RateLimitInfo) low() bool {
+	return i.NearLimit || (i.Limit > 0 && float64(i.Remaining) <= float64(i.Limit)*jiraThrottleRatio)
+}
+
+// Jiraのレスポンスの X-RateLimit-* ヘッダを記録し、残量が少ないときは呼び出し間隔を空ける
+// 429 で Retry-After が返った場合はその時刻まで後続のリクエストを待たせる
+// Server/Data Center などヘッダを返さない環境では何もしない
+type rateLimitTransport struct {
+	base http.RoundTripper
+
+	mu           sync.Mutex
+	info         RateLimitInfo
+	observed     bool
+	blockedUntil time.Time
+	nextRequest  time.Time
+}
+
+func newRateLimitTransport() *rateLimitTransport {
+	return &rateLimitTransport{base: http.DefaultTransport}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if wait := t.delay(); wait > 0 {
+		slog.DebugContext(req.Context(), "Throttle Jira request", slog.Duration("wait", wait))
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.observe(req.Context(), resp)
+	return resp, nil
+}
+
+// 次のリクエストまでに待つ時間を返す
+func (t *rateLimitTransport) delay() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Before(t.blockedUntil) {
+		return t.blockedUntil.Sub(now)
+	}
+	if !t.info.low() {
+		return 0
+	}
+	// 並列のリクエストも間隔を空けて順に送るよう、次の送信可能時刻を予約する
+	start := now
+	if t.nextRequest.After(now) {
+		start = t.nextRequest
+	}
+	t.nextRequest = start.Add(jiraThrottleInterval)
+	return start.Sub(now)
+}
+
+func (t *rateLimitTransport) observe(ctx context.Context, resp *http.Response) {
+	limit, hasLimit := headerInt(resp.Header, "X-RateLimit-Limit")
+	remaining, hasRemaining := headerInt(resp.Header, "X-RateLimit-Remaining")
+	nearLimit := resp.Header.Get("X-RateLimit-NearLimit") == "true"
+	retryAfter, hasRetryAfter := headerInt(resp.Header, "Retry-After")
+	if !hasLimit && !hasRemaining && !nearLimit && !hasRetryAfter {
+		return
+	}
+
+	info := RateLimitInfo{
+		Limit:     limit,
+		Remaining: remaining,
+		NearLimit: nearLimit,
+		UpdatedAt: time.Now(),
+	}
+	if reset, err := time.Parse(time.RFC3339, resp.Header.Get("X-RateLimit-Reset")); err == nil {
+		info.Reset = reset
+	}
+
+	t.mu.Lock()
+	t.info = info
+	t.observed = true
+	if resp.StatusCode == http.StatusTooManyRequests && hasRetryAfter {
+		t.blockedUntil = time.Now().Add(time.Duration(retryAfter) * time.Second)
+	}
+	t.mu.Unlock()
+
+	attrs := []any{
+		slog.Int("status", resp.StatusCode),
+		slog.Int("limit", info.Limit),
+		slog.Int("remaining", info.Remaining),
+		slog.Bool("near_limit", info.NearLimit),
+		slog.Time("reset", info.Reset),
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		slog.WarnContext(ctx, "Jira rate limit exceeded", append(attrs, slog.Int("retry_after_sec", retryAfter))...)
+	case info.low():
+		slog.WarnContext(ctx, "Jira rate limit is running low, throttling requests", attrs...)
+	default:
+		slog.DebugContext(ctx, "Jira rate limit", attrs...)
+	}
+}
+
+// 直近のレスポンスから得たレートリミットの状態を返す
+func (t *rateLimitTransport) snapshot() (RateLimitInfo, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.info, t.observed
+}
+
+func headerInt(h http.Header, key string) (int, bool) {
+	v, err := strconv.Atoi(h.Get(key))
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// RateLimit はJiraのレートリミットの直近の状態を返す
+// ヘッダを受け取っていない場合は ok が false
+func (h *Jira) RateLimit() (RateLimitInfo, bool) {
+	return h.rateLimit.snapshot()
+}
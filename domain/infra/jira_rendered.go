@@ -0,0 +1,93 @@
+package infra
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// expand=renderedFields で返るレンダリング済みHTMLのうち使用する項目
+type renderedFields struct {
+	Description string `json:"description"`
+	Comment     struct {
+		Comments []struct {
+			Body string `json:"body"`
+		} `json:"comments"`
+	} `json:"comment"`
+}
+
+// コメントのプレーンテキストを取得する
+// レンダリング済みHTMLがある場合はADFより優先する
+func (c Comment) text() string {
+	if c.RenderedBody != "" {
+		return htmlToText(c.RenderedBody)
+	}
+	return extractTextFromADF(c.Body)
+}
+
+// renderedFields のコメントは fields のコメントと同じ順序で返るため、位置で対応付ける
+func applyRenderedComments(issues []Issue) {
+	for i := range issues {
+		rendered := issues[i].RenderedFields
+		if rendered == nil {
+			continue
+		}
+		comments := issues[i].Fields.Comment.Comments
+		for j := range comments {
+			if j >= len(rendered.Comment.Comments) {
+				break
+			}
+			comments[j].RenderedBody = rendered.Comment.Comments[j].Body
+		}
+	}
+}
+
+// 改行として扱う要素
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Pre: true, atom.Blockquote: true, atom.Table: true, atom.Ul: true, atom.Ol: true,
+}
+
+// htmlToText はHTMLからタグを除いたテキストを取り出す
+// パースに失敗した場合は入力をそのまま返す
+func htmlToText(s string) string {
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+				return
+			}
+			if n.DataAtom == atom.Td || n.DataAtom == atom.Th {
+				b.WriteString(" ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && htmlBlockElements[n.DataAtom] {
+			b.WriteString("\n")
+		}
+	}
+	walk(doc)
+
+	// 空行と行内の連続する空白を詰める
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
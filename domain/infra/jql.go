@@ -0,0 +1,177 @@
+package infra
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+)
+
+// LLMが間違えやすいフィールド名の補正
+var jqlFieldAliases = map[string]string{
+	"priorty":     "priority",
+	"asignee":     "assignee",
+	"assignees":   "assignee",
+	"reportor":    "reporter",
+	"create":      "created",
+	"createddate": "created",
+	"update":      "updated",
+	"updateddate": "updated",
+	"desc":        "description",
+	"comments":    "comment",
+	"label":       "labels",
+	"resolved":    "resolutiondate",
+}
+
+// フィールド一覧に含まれないがJQLで使用できる項目
+var jqlBuiltinFields = map[string]bool{
+	"text":        true,
+	"key":         true,
+	"issuekey":    true,
+	"issue":       true,
+	"id":          true,
+	"project":     true,
+	"parent":      true,
+	"sprint":      true,
+	"filter":      true,
+	"savedfilter": true,
+	"request":     true,
+}
+
+var (
+	// 引用符で囲まれた値（値の中の語をフィールドと誤認しないために除去する）
+	jqlQuotedPattern = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`)
+	// 演算子の直前にある語をフィールド名として抽出する
+	jqlFieldPattern = regexp.MustCompile(`(?i)([A-Za-z_][\w.]*(?:\[\d+\])?)\s*(?:!=|!~|>=|<=|=|~|>|<|\s(?:not\s+in|in|is|was|changed)\b)`)
+)
+
+// JQL_TEMPLATE でキーワードを差し込む位置
+const jqlTemplatePlaceholder = "{{keywords}}"
+
+// 全文検索の演算子として解釈される文字（キーワードからは除去する）
+var jqlTextSpecialChars = strings.NewReplacer(
+	`"`, " ", `\`, " ", "'", " ",
+	"+", " ", "-", " ", "&", " ", "|", " ", "!", " ", "(", " ", ")", " ",
+	"{", " ", "}", " ", "[", " ", "]", " ", "^", " ", "~", " ", "*", " ",
+	"?", " ", ":", " ",
+)
+
+// UsesTemplate は JQL_TEMPLATE によるキーワード差し込みのモードかを返す
+func (h *Jira) UsesTemplate() bool {
+	return h.jqlTemplate != ""
+}
+
+// TemplateQuery は JQL_TEMPLATE の {{keywords}} にキーワードを差し込んだJQLを返す
+// 引用符や全文検索の演算子はJQLを壊さないよう除去する
+func (h *Jira) TemplateQuery(keywords []string) string {
+	var words []string
+	for _, k := range keywords {
+		words = append(words, strings.Fields(jqlTextSpecialChars.Replace(k))...)
+	}
+	return strings.ReplaceAll(h.jqlTemplate, jqlTemplatePlaceholder, strings.Join(words, " "))
+}
+
+// フィールド一覧のキャッシュ期間
+const jqlFieldCacheTTL = time.Hour
+
+func newFieldCache() *ttlcache.Cache[string, map[string]bool] {
+	cache := ttlcache.New(ttlcache.WithTTL[string, map[string]bool](jqlFieldCacheTTL))
+	go cache.Start()
+	return cache
+}
+
+// JQLで使用されているフィールド名を抽出する
+func jqlFields(query string) []string {
+	query = jqlQuotedPattern.ReplaceAllString(query, `""`)
+	if loc := orderByPattern.FindStringIndex(query); loc != nil {
+		query = query[:loc[0]]
+	}
+	var fields []string
+	for _, m := range jqlFieldPattern.FindAllStringSubmatch(query, -1) {
+		fields = append(fields, m[1])
+	}
+	return fields
+}
+
+// エイリアスに該当するフィールド名を補正する
+func normalizeJQLFields(query string) string {
+	for _, field := range jqlFields(query) {
+		alias, ok := jqlFieldAliases[strings.ToLower(field)]
+		if !ok || alias == field {
+			continue
+		}
+		re := regexp.MustCompile(`\b` + regexp.QuoteMeta(field) + `\b(\s*(?:!=|!~|>=|<=|=|~|>|<|\s))`)
+		query = re.ReplaceAllString(query, alias+"$1")
+		slog.Info("JQL field corrected", slog.String("from", field), slog.String("to", alias))
+	}
+	return query
+}
+
+// fetchFieldNames はJiraの有効なフィールド名（ID・名前・JQL上の名前）を小文字で返す
+func (h *Jira) fetchFieldNames() (map[string]bool, error) {
+	const cacheKey = "fields"
+	if item := h.fieldCache.Get(cacheKey); item != nil {
+		return item.Value(), nil
+	}
+
+	req, err := h.client.NewRequest("GET", h.apiPath("field"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	var fields []struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		ClauseNames []string `json:"clauseNames"`
+	}
+	if _, err := h.client.Do(req, &fields); err != nil {
+		return nil, fmt.Errorf("failed to fetch Jira fields: %w", err)
+	}
+
+	names := make(map[string]bool, len(fields)*2)
+	for _, f := range fields {
+		names[strings.ToLower(f.ID)] = true
+		names[strings.ToLower(f.Name)] = true
+		for _, c := range f.ClauseNames {
+			names[strings.ToLower(c)] = true
+		}
+	}
+	h.fieldCache.Set(cacheKey, names, ttlcache.DefaultTTL)
+	return names, nil
+}
+
+// ValidateQueries は生成されたJQLのフィールド名をエイリアスで補正し、存在しないフィールドや禁止された関数があればエラーを返す
+// フィールド一覧が取得できない場合はフィールド名の検証のみ行わない
+func (h *Jira) ValidateQueries(queries []string) ([]string, error) {
+	normalized := make([]string, 0, len(queries))
+	for _, query := range queries {
+		query = normalizeJQLFields(query)
+		if funcs := h.disallowedFunctionsIn(query); len(funcs) > 0 {
+			return nil, &JQLError{Query: query, DisallowedFunctions: funcs}
+		}
+		normalized = append(normalized, query)
+	}
+
+	names, err := h.fetchFieldNames()
+	if err != nil {
+		slog.Warn("Failed to fetch Jira fields, skip JQL validation", slog.Any("err", err))
+		return normalized, nil
+	}
+
+	for _, query := range normalized {
+		var unknown []string
+		for _, field := range jqlFields(query) {
+			lower := strings.ToLower(field)
+			if names[lower] || jqlBuiltinFields[lower] || strings.HasPrefix(lower, "cf[") {
+				continue
+			}
+			unknown = append(unknown, field)
+		}
+		if len(unknown) > 0 {
+			return nil, &JQLError{Query: query, UnknownFields: unknown}
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,151 @@
+package infra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// JQLError はJQLの検証・検索で発生したエラーの内容を保持する
+// 検索クエリの再生成時に修正指示として使う
+type JQLError struct {
+	Query      string
+	StatusCode int
+	// 存在しないフィールド名
+	UnknownFields []string
+	// 使用が禁止されている関数名（currentUser() など）
+	DisallowedFunctions []string
+	// フィールドに対して存在しない値（フィールド名 → 値）
+	InvalidValues map[string]string
+	// 構文エラーの位置（1始まり、不明な場合は 0）
+	Line      int
+	Character int
+	// 上記に分類できなかったエラーメッセージ
+	Messages []string
+}
+
+func (e *JQLError) Error() string {
+	var parts []string
+	if len(e.UnknownFields) > 0 {
+		parts = append(parts, "unknown JQL fields: "+strings.Join(e.UnknownFields, ", "))
+	}
+	if len(e.DisallowedFunctions) > 0 {
+		parts = append(parts, "disallowed JQL functions: "+strings.Join(e.DisallowedFunctions, ", "))
+	}
+	for field, value := range e.InvalidValues {
+		parts = append(parts, fmt.Sprintf("invalid value %q for field %s", value, field))
+	}
+	if e.Line > 0 {
+		parts = append(parts, fmt.Sprintf("syntax error at line %d, character %d", e.Line, e.Character))
+	}
+	parts = append(parts, e.Messages...)
+	if len(parts) == 0 {
+		return fmt.Sprintf("Jira search failed with status %d", e.StatusCode)
+	}
+	return strings.Join(parts, "; ")
+}
+
+var (
+	jqlUnknownFieldPattern   = regexp.MustCompile(`(?i)field '([^']+)' does not exist`)
+	jqlInvalidValuePattern   = regexp.MustCompile(`(?i)the value '([^']+)' does not exist for the field '([^']+)'`)
+	jqlSyntaxPositionPattern = regexp.MustCompile(`(?i)line (\d+), character (\d+)`)
+)
+
+// newJQLError はJira APIのエラーレスポンス（errorMessages / errors）を解析する
+// レスポンスが無い場合は元のエラーを、解析できない場合はステータスを付けたエラーを返す
+func newJQLError(query string, resp *jira.Response, err error) error {
+	if resp == nil {
+		return err
+	}
+	if resp.Body == nil {
+		return &StatusError{StatusCode: resp.StatusCode, Err: err}
+	}
+	defer resp.Body.Close()
+	body, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return &StatusError{StatusCode: resp.StatusCode, Err: err}
+	}
+	var payload struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return &StatusError{StatusCode: resp.StatusCode, Err: err}
+	}
+
+	jqlErr := &JQLError{Query: query, StatusCode: resp.StatusCode}
+	messages := payload.ErrorMessages
+	for _, m := range payload.Errors {
+		messages = append(messages, m)
+	}
+	for _, m := range messages {
+		jqlErr.classify(m)
+	}
+	return jqlErr
+}
+
+// エラーメッセージを分類して取り込む
+func (e *JQLError) classify(message string) {
+	if m := jqlInvalidValuePattern.FindStringSubmatch(message); m != nil {
+		if e.InvalidValues == nil {
+			e.InvalidValues = make(map[string]string)
+		}
+		e.InvalidValues[m[2]] = m[1]
+		return
+	}
+	if m := jqlUnknownFieldPattern.FindStringSubmatch(message); m != nil {
+		e.UnknownFields = append(e.UnknownFields, m[1])
+		return
+	}
+	if m := jqlSyntaxPositionPattern.FindStringSubmatch(message); m != nil && e.Line == 0 {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Character, _ = strconv.Atoi(m[2])
+	}
+	e.Messages = append(e.Messages, message)
+}
+
+// Instruction は前回の失敗を踏まえた検索クエリの修正指示を返す
+func (e *JQLError) Instruction() string {
+	var lines []string
+	if e.Query != "" {
+		lines = append(lines, fmt.Sprintf("- 失敗したクエリ: %s", e.Query))
+	}
+	if len(e.UnknownFields) > 0 {
+		lines = append(lines, fmt.Sprintf("- フィールド %s は存在しません。使用せず、代わりに text ~ \"キーワード\" で検索してください", strings.Join(e.UnknownFields, ", ")))
+	}
+	if len(e.DisallowedFunctions) > 0 {
+		lines = append(lines, fmt.Sprintf("- 関数 %s はBotの実行ユーザーに依存し問い合わせ者の意図と異なる結果になるため、使用しないでください", strings.Join(e.DisallowedFunctions, ", ")))
+	}
+	for field, value := range e.InvalidValues {
+		lines = append(lines, fmt.Sprintf("- フィールド %s に値 %q は存在しません。この条件を外してください", field, value))
+	}
+	if e.Line > 0 {
+		lines = append(lines, fmt.Sprintf("- %d行目%d文字目付近に構文エラーがあります。引用符の閉じ忘れや演算子の誤りを修正してください", e.Line, e.Character))
+	}
+	for _, m := range e.Messages {
+		lines = append(lines, fmt.Sprintf("- Jiraのエラー: %s", m))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// retryInstruction は前回のエラーをプロンプトに渡す修正指示に整形する。エラーが無い場合は空を返す
+func retryInstruction(lastError error) string {
+	if lastError == nil {
+		return ""
+	}
+	instruction := fmt.Sprintf("- %s", lastError)
+	var jqlErr *JQLError
+	if errors.As(lastError, &jqlErr) {
+		instruction = jqlErr.Instruction()
+	}
+	return fmt.Sprintf(`## 前回の検索の失敗
+前回生成したクエリは以下の理由で失敗しました。同じ誤りを繰り返さないよう修正してください。
+%s
+`, instruction)
+}
@@ -0,0 +1,58 @@
+package infra
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 実行ユーザー（Bot自身）に依存し、問い合わせ者の意図とずれる結果になるJQL関数
+// JQL_ALLOWED_FUNCS に指定したものは許可する
+var jqlDisallowedFunctions = []string{
+	"currentUser",
+	"currentLogin",
+	"lastLogin",
+	"issueHistory",
+	"watchedIssues",
+	"votedIssues",
+}
+
+// 括弧の直前にある語を関数名として抽出する
+var jqlFunctionPattern = regexp.MustCompile(`\b([A-Za-z_]\w*)\s*\(`)
+
+// 括弧が続いても関数ではないJQLのキーワード
+var jqlKeywords = map[string]bool{
+	"in": true, "not": true, "and": true, "or": true, "was": true, "is": true,
+	"changed": true, "by": true, "from": true, "to": true, "on": true,
+	"during": true, "before": true, "after": true,
+}
+
+// JQL_ALLOWED_FUNCS を除いた、使用を禁止する関数名（小文字）を返す
+func disallowedJQLFunctions(allowed []string) map[string]bool {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, f := range allowed {
+		allowedSet[strings.ToLower(strings.TrimSuffix(f, "()"))] = true
+	}
+	disallowed := make(map[string]bool, len(jqlDisallowedFunctions))
+	for _, f := range jqlDisallowedFunctions {
+		if lower := strings.ToLower(f); !allowedSet[lower] {
+			disallowed[lower] = true
+		}
+	}
+	return disallowed
+}
+
+// JQLで使用されている禁止された関数名を返す（引用符内の語は対象外）
+func (h *Jira) disallowedFunctionsIn(query string) []string {
+	query = jqlQuotedPattern.ReplaceAllString(query, `""`)
+	var found []string
+	seen := make(map[string]bool)
+	for _, m := range jqlFunctionPattern.FindAllStringSubmatch(query, -1) {
+		lower := strings.ToLower(m[1])
+		if jqlKeywords[lower] || !h.disallowedFuncs[lower] || seen[lower] {
+			continue
+		}
+		seen[lower] = true
+		found = append(found, m[1]+"()")
+	}
+	return found
+}
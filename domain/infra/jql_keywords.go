@@ -0,0 +1,121 @@
+package infra
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 問い合わせから機械的に抽出したキーワードのうち、検索の絞り込みに役立たない語
+var inquiryStopWords = map[string]bool{
+	"問題": true, "確認": true, "対応": true, "質問": true, "方法": true, "状況": true,
+	"発生": true, "原因": true, "可能": true, "現在": true, "エラー": true, "お願い": true,
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true, "please": true,
+}
+
+// 文字種（漢字・カタカナ・英数字）ごとの連続をキーワードの候補とする
+// ひらがなや記号は助詞・区切りとして扱い、キーワードに含めない
+func runeClass(r rune) int {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return 1
+	case unicode.Is(unicode.Katakana, r) || r == 'ー':
+		return 2
+	case unicode.Is(unicode.Hiragana, r):
+		return 0
+	case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_':
+		return 3
+	default:
+		return 0
+	}
+}
+
+// inquiryKeywords は問い合わせ文から検索に使えそうな語を出現順に抽出する
+// 1文字の語・一般的すぎる語・重複は除外する
+func inquiryKeywords(text string) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	add := func(word string) {
+		word = strings.Trim(word, "-_")
+		lower := strings.ToLower(word)
+		if utf8.RuneCountInString(word) < 2 || seen[lower] || inquiryStopWords[lower] {
+			return
+		}
+		seen[lower] = true
+		keywords = append(keywords, word)
+	}
+
+	var current []rune
+	currentClass := 0
+	for _, r := range text {
+		class := runeClass(r)
+		if class != currentClass {
+			add(string(current))
+			current = current[:0]
+			currentClass = class
+		}
+		if class != 0 {
+			current = append(current, r)
+		}
+	}
+	add(string(current))
+	return keywords
+}
+
+// JQLの全文検索の条件に含まれるキーワード（重複を除く）
+func textClauseKeywords(query string) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, m := range jqlTextClausePattern.FindAllStringSubmatch(query, -1) {
+		for _, word := range strings.Fields(m[3]) {
+			if lower := strings.ToLower(word); !seen[lower] {
+				seen[lower] = true
+				keywords = append(keywords, word)
+			}
+		}
+	}
+	return keywords
+}
+
+// supplementKeywords は不足するキーワードを問い合わせ文から補う
+// 既に含まれている語は追加せず、補える語が無い場合はそのまま返す
+func supplementKeywords(keywords []string, inquiry string, minKeywords int) []string {
+	missing := minKeywords - len(keywords)
+	if missing <= 0 {
+		return keywords
+	}
+	seen := make(map[string]bool)
+	for _, k := range keywords {
+		seen[strings.ToLower(k)] = true
+	}
+	for _, word := range inquiryKeywords(inquiry) {
+		if missing == 0 {
+			break
+		}
+		if seen[strings.ToLower(word)] {
+			continue
+		}
+		keywords = append(keywords, word)
+		missing--
+	}
+	return keywords
+}
+
+// ensureMinKeywords はJQLの全文検索のキーワードが minKeywords 個に満たない場合に問い合わせ文から補う
+// 既存の最初の全文検索の条件に語を追加し、条件が無い場合は text ~ "..." を AND で付与する
+func ensureMinKeywords(query, inquiry string, minKeywords int) string {
+	current := textClauseKeywords(query)
+	supplemented := supplementKeywords(current, inquiry, minKeywords)
+	added := supplemented[len(current):]
+	if len(added) == 0 {
+		return query
+	}
+
+	if loc := jqlTextClausePattern.FindStringSubmatchIndex(query); loc != nil {
+		// 引用符の閉じ位置の直前にキーワードを追加する
+		end := loc[7]
+		return query[:end] + " " + strings.Join(added, " ") + query[end:]
+	}
+	return andCondition(query, fmt.Sprintf(`text ~ "%s"`, strings.Join(added, " ")))
+}
@@ -0,0 +1,38 @@
+package infra
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 更新日・作成日・解決日を比較する条件（既に期間が指定されているとみなす）
+var jqlPeriodPattern = regexp.MustCompile(`(?i)\b(?:updated|updateddate|created|createddate|resolved|resolutiondate)\s*(?:>=|<=|>|<|\s(?:during|after|before)\b)`)
+
+// JIRA_SEARCH_DAYS が設定されていれば、更新日がその日数以内の条件をJQLに付与する
+// LLMが生成したクエリに既に期間の条件がある場合はそれを尊重して付与しない
+func (h *Jira) withSearchPeriod(query string) string {
+	if h.searchDays <= 0 || hasPeriodCondition(query) {
+		return query
+	}
+	return andCondition(query, fmt.Sprintf("updated >= -%dd", h.searchDays))
+}
+
+// 引用符内の語を誤検出しないよう、値を除いてから期間の条件を探す
+func hasPeriodCondition(query string) bool {
+	return jqlPeriodPattern.MatchString(jqlQuotedPattern.ReplaceAllString(query, `""`))
+}
+
+// JQLに AND で条件を付与する
+// ORDER BY がある場合はその前に条件を差し込む
+func andCondition(query, cond string) string {
+	orderBy := ""
+	if loc := orderByPattern.FindStringIndex(query); loc != nil {
+		query, orderBy = query[:loc[0]], " "+query[loc[0]:]
+	}
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return cond + orderBy
+	}
+	return fmt.Sprintf("(%s) AND %s%s", query, cond, orderBy)
+}
@@ -0,0 +1,60 @@
+package infra
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RelaxLevel は検索結果が0件の場合にJQLを緩和する段階
+type RelaxLevel int
+
+const (
+	// 全文検索のキーワードを減らす
+	RelaxKeywords RelaxLevel = iota + 1
+	// プロジェクトの絞り込みのみで検索する
+	RelaxProjectOnly
+)
+
+// RelaxLevels は緩和を試す順序
+var RelaxLevels = []RelaxLevel{RelaxKeywords, RelaxProjectOnly}
+
+// 全文検索の条件（フィールド ~ "キーワード"）
+var jqlTextClausePattern = regexp.MustCompile(`(?i)\b(text|summary|description|comment|environment)(\s*~\s*)"((?:[^"\\]|\\.)*)"`)
+
+// RelaxQueries は指定した段階に応じて緩和したJQLを返す
+// 緩和しても元のクエリと変わらないものは含めない
+func (h *Jira) RelaxQueries(queries []string, level RelaxLevel) []string {
+	switch level {
+	case RelaxKeywords:
+		var relaxed []string
+		seen := make(map[string]bool)
+		for _, q := range queries {
+			r := reduceTextKeywords(q)
+			if r == q || seen[r] {
+				continue
+			}
+			seen[r] = true
+			relaxed = append(relaxed, r)
+		}
+		return relaxed
+	case RelaxProjectOnly:
+		if len(h.projectKeys) == 0 {
+			return nil
+		}
+		return []string{fmt.Sprintf("project in (%s)", strings.Join(h.projectKeys, ", "))}
+	}
+	return nil
+}
+
+// 全文検索の条件のキーワードを前半の半分（最低1語）に減らす
+func reduceTextKeywords(query string) string {
+	return jqlTextClausePattern.ReplaceAllStringFunc(query, func(clause string) string {
+		m := jqlTextClausePattern.FindStringSubmatch(clause)
+		words := strings.Fields(m[3])
+		if len(words) <= 1 {
+			return clause
+		}
+		return fmt.Sprintf(`%s%s"%s"`, m[1], m[2], strings.Join(words[:(len(words)+1)/2], " "))
+	})
+}
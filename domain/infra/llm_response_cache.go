@@ -0,0 +1,81 @@
+package infra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/pyama86/jipcy/config"
+)
+
+// llmResponseCache はプロンプト全文のハッシュをキーにLLMのレスポンスをファイルにキャッシュする
+// 開発・テストで同じプロンプトを再送しないため、また決定的な結果を得るために使う
+// LLM_RESPONSE_CACHE が無効の場合は nil とし、何もキャッシュしない
+type llmResponseCache struct {
+	dir string
+}
+
+// ファイルに保存するレスポンス
+type cachedLLMResponse struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
+}
+
+func newLLMResponseCache(cfg config.OpenAIConfig) (*llmResponseCache, error) {
+	if !cfg.ResponseCache {
+		return nil, nil
+	}
+	dir := cfg.ResponseCacheDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "jipcy-llm-responses")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create LLM response cache dir: %w", err)
+	}
+	slog.Warn("LLM response cache is enabled", slog.String("dir", dir))
+	return &llmResponseCache{dir: dir}, nil
+}
+
+// モデル・JSONモードの有無・プロンプト全文からキャッシュのキーを生成する
+func llmResponseCacheKey(model string, jsonMode bool, prompt string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%t\x00%s", model, jsonMode, prompt)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *llmResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *llmResponseCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	var cached cachedLLMResponse
+	if err := json.Unmarshal(b, &cached); err != nil {
+		slog.Warn("Broken LLM response cache file, ignored", slog.String("path", c.path(key)), slog.Any("err", err))
+		return "", false
+	}
+	slog.Debug("LLM response cache hit", slog.String("key", key), slog.String("model", cached.Model))
+	return cached.Content, true
+}
+
+func (c *llmResponseCache) set(key, model, content string) {
+	if c == nil {
+		return
+	}
+	b, err := json.Marshal(cachedLLMResponse{Model: model, Content: content})
+	if err == nil {
+		err = writeFileAtomic(c.dir, key+".json", b)
+	}
+	if err != nil {
+		slog.Warn("Failed to store LLM response cache", slog.String("key", key), slog.Any("err", err))
+	}
+}
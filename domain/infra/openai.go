@@ -2,115 +2,580 @@ package infra
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	ttlcache "github.com/jellydator/ttlcache/v3"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/azure"
 	"github.com/openai/openai-go/option"
+	"github.com/pyama86/jipcy/config"
 	"github.com/pyama86/jipcy/domain/model"
 	"github.com/songmu/retry"
 )
 
+// 1回の問い合わせで生成するJira検索クエリの最大数
+const maxJiraQueries = 3
+
 type OpenAI struct {
 	client *openai.Client
+	// client の呼び出しに失敗した場合に再試行するクライアント（LLM_FALLBACK 未設定の場合は nil）
+	fallbackClient *openai.Client
+	condenseCache  *ttlcache.Cache[string, string]
+	cfg            config.OpenAIConfig
+	// 検索クエリの生成に使うJiraの設定
+	jira config.JiraConfig
+	// auto モードでJSONモード非対応と判明した場合に以降の呼び出しで使わないためのフラグ
+	jsonModeUnsupported atomic.Bool
+	// ストリーミング非対応と判明した場合に以降の呼び出しで使わないためのフラグ
+	streamUnsupported atomic.Bool
+	embeddingCache    *EmbeddingCache
+	// プロンプトごとのレスポンスのファイルキャッシュ（LLM_RESPONSE_CACHE 無効時は nil）
+	responseCache *llmResponseCache
+	// 内容が乏しく類似度計算を省略した件数
+	similaritySkipped atomic.Int64
 }
 
-func NewOpenAI() (*OpenAI, error) {
-	client, err := newOpenAIClient()
+func NewOpenAI(cfg *config.Config) (*OpenAI, error) {
+	if err := validateModels(cfg.OpenAI); err != nil {
+		return nil, err
+	}
+
+	client := newOpenAIClient(cfg.OpenAI, cfg.OpenAI.Primary)
+	var fallbackClient *openai.Client
+	if cfg.OpenAI.Fallback != "" {
+		fallbackClient = newOpenAIClient(cfg.OpenAI, cfg.OpenAI.Fallback)
+		slog.Info("LLM fallback is enabled", slog.String("primary", cfg.OpenAI.Primary), slog.String("fallback", cfg.OpenAI.Fallback))
+	}
+
+	embeddingCache, err := NewEmbeddingCache(cfg.OpenAI)
+	if err != nil {
+		return nil, err
+	}
+	responseCache, err := newLLMResponseCache(cfg.OpenAI)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize OpenAI client: %w", err)
+		return nil, err
 	}
-	return &OpenAI{
-		client: client,
-	}, nil
+
+	o := &OpenAI{
+		client:         client,
+		fallbackClient: fallbackClient,
+		condenseCache:  ttlcache.New(ttlcache.WithTTL[string, string](time.Hour * 24)),
+		cfg:            cfg.OpenAI,
+		jira:           cfg.Jira,
+		embeddingCache: embeddingCache,
+		responseCache:  responseCache,
+	}
+	go o.condenseCache.Start()
+	return o, nil
 }
 
-func newOpenAIClient() (*openai.Client, error) {
-	if os.Getenv("AZURE_OPENAI_ENDPOINT") != "" {
-		return newAzureClient()
+// 空のモデル名のまま呼び出すと原因のわかりにくいエラーになるため、起動時に検証する
+// Azure ではモデル名としてデプロイ名を指定する必要がある
+func validateModels(cfg config.OpenAIConfig) error {
+	models := []struct{ key, value string }{
+		{"OPENAI_MODEL", cfg.Model},
+		{"OPENAI_EMBEDDING_MODEL", cfg.EmbeddingModel},
+	}
+	// OPENAI_CONDENSE_MODEL は未指定時に OPENAI_MODEL を使うため、OPENAI_MODEL がある場合のみ検証する
+	if cfg.Model != "" {
+		models = append(models, struct{ key, value string }{"OPENAI_CONDENSE_MODEL", cfg.CondenseModel})
 	}
+	var errs []error
+	for _, m := range models {
+		if strings.TrimSpace(m.value) != "" {
+			continue
+		}
+		if cfg.IsAzure() || cfg.Fallback == config.LLMProviderAzure {
+			errs = append(errs, fmt.Errorf("%s is required: set the Azure OpenAI deployment name", m.key))
+		} else {
+			errs = append(errs, fmt.Errorf("%s is required: set the model name (e.g. gpt-4o-mini)", m.key))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	key := os.Getenv("OPENAI_API_KEY")
-	if key == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY is not set")
+// 指定したプロバイダのクライアントを生成する。APIキーの有無は LoadConfig で検証済み
+func newOpenAIClient(cfg config.OpenAIConfig, provider string) *openai.Client {
+	if provider == config.LLMProviderAzure {
+		return newAzureClient(cfg)
 	}
+
 	options := []option.RequestOption{
-		option.WithAPIKey(os.Getenv("OPENAI_API_KEY")),
+		option.WithAPIKey(cfg.APIKey),
+		option.WithMiddleware(rateLimitMiddleware),
 	}
 
-	return openai.NewClient(options...), nil
+	return openai.NewClient(options...)
 }
 
-func newAzureClient() (*openai.Client, error) {
-	key := os.Getenv("AZURE_OPENAI_KEY")
-	if key == "" {
-		return nil, fmt.Errorf("AZURE_OPENAI_KEY is not set")
+func newAzureClient(cfg config.OpenAIConfig) *openai.Client {
+	return openai.NewClient(
+		azure.WithEndpoint(cfg.AzureEndpoint, cfg.AzureAPIVersion),
+		azure.WithAPIKey(cfg.AzureKey),
+		option.WithMiddleware(rateLimitMiddleware),
+	)
+}
+
+// 見出し行と空白を除いた文字数を返す
+// formatIssue の見出しだけで本文の無い課題を空とみなすため
+func contentLength(text string) int {
+	n := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		n += utf8.RuneCountInString(strings.Join(strings.Fields(line), ""))
 	}
-	var azureOpenAIEndpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+	return n
+}
 
-	var azureOpenAIAPIVersion = "2025-01-01-preview"
+// レスポンスの最初の選択肢の本文を取り出す関数
+// choicesが空の場合やコンテンツフィルタで止められた場合はエラーを返し、呼び出し元のリトライに委ねる
+func firstChoiceContent(response *openai.ChatCompletion) (string, error) {
+	if response == nil || len(response.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI API returned no choices")
+	}
+	choice := response.Choices[0]
+	if choice.FinishReason == openai.ChatCompletionChoicesFinishReasonContentFilter {
+		slog.Warn("OpenAI response was blocked by content filter", slog.String("model", response.Model))
+		return "", fmt.Errorf("OpenAI response was blocked by content filter")
+	}
+	if choice.Message.Content == "" {
+		return "", fmt.Errorf("OpenAI API returned empty content (finish_reason: %s)", choice.FinishReason)
+	}
+	return choice.Message.Content, nil
+}
 
-	if os.Getenv("AZURE_OPENAI_API_VERSION") != "" {
-		azureOpenAIAPIVersion = os.Getenv("AZURE_OPENAI_API_VERSION")
+// JSONを返すプロンプトを実行し、レスポンス本文のJSONを返す関数
+// OPENAI_JSON_MODE が auto の場合、response_format が拒否されたらプロンプトでの指示に切り替えて再試行する
+func (h *OpenAI) completeJSON(ctx context.Context, prompt string) (string, error) {
+	useJSONMode := h.cfg.JSONMode == config.JSONModeOn || (h.cfg.JSONMode == config.JSONModeAuto && !h.jsonModeUnsupported.Load())
+	if useJSONMode {
+		content, err := h.complete(ctx, prompt, true)
+		if err == nil || h.cfg.JSONMode == config.JSONModeOn || !isJSONModeUnsupportedError(err) {
+			return content, err
+		}
+		slog.WarnContext(ctx, "JSON mode is not supported by the model, fallback to prompt instruction", slog.Any("err", err))
+		h.jsonModeUnsupported.Store(true)
 	}
 
-	return openai.NewClient(
-		azure.WithEndpoint(azureOpenAIEndpoint, azureOpenAIAPIVersion),
-		azure.WithAPIKey(key),
-	), nil
+	content, err := h.complete(ctx, prompt+"\n\n説明やコードブロックを含めず、JSONオブジェクトのみを返してください。", false)
+	if err != nil {
+		return "", err
+	}
+	return extractJSONObject(content)
 }
 
-// GenerateSummaryForIssue は単一のIssueに対して要約を生成する（goroutine対応・retry機能付き）
-func (h *OpenAI) GenerateSummaryForIssue(issue *model.Result) error {
-	// retry機能付きで要約生成を実行
-	return retry.Retry(3, 3*time.Second, func() error {
-		prompt := fmt.Sprintf(`## 依頼内容
-以下のJiraの課題の内容と、その課題の解決方法(主にコメントとして記載されている)の結果をサマリとして自然言語で返答してください。
-あなたが作成した結果の用途は新しく課題をjiraに作成するかどうかを判断するためなので簡潔に類似かどうか判断できる材料をください。
-またまだ未解決のものであれば嘘をつかずに、未解決と書いてください。
-よくわからないことはよくわからないと書いてください。
+func (h *OpenAI) complete(ctx context.Context, prompt string, jsonMode bool) (string, error) {
+	params := openai.ChatCompletionNewParams{
+		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage(prompt),
+		}),
+		Model: openai.F(h.cfg.Model),
+	}
+	if jsonMode {
+		params.ResponseFormat = openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
+			openai.ResponseFormatJSONObjectParam{
+				Type: openai.F(openai.ResponseFormatJSONObjectTypeJSONObject),
+			},
+		)
+	}
+
+	cacheKey := llmResponseCacheKey(h.cfg.Model, jsonMode, prompt)
+	if content, ok := h.responseCache.get(cacheKey); ok {
+		return content, nil
+	}
 
-## メンション形式について：
+	response, err := withFallback(ctx, h, func(client *openai.Client) (*openai.ChatCompletion, error) {
+		return client.Chat.Completions.New(ctx, params)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to call OpenAI API: %w", err)
+	}
+	content, err := firstChoiceContent(response)
+	if err != nil {
+		return "", err
+	}
+	h.dump(h.cfg.Model, prompt, content)
+	h.responseCache.set(cacheKey, h.cfg.Model, content)
+	return content, nil
+}
+
+// response_format が非対応であることによるエラーかを判定する
+func isJSONModeUnsupportedError(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return apiErr.Param == "response_format" || strings.Contains(apiErr.Message, "response_format")
+}
+
+// 本文から最初のJSONオブジェクトを抽出する関数
+func extractJSONObject(content string) (string, error) {
+	start := strings.Index(content, "{")
+	if start == -1 {
+		return "", fmt.Errorf("no JSON object found in OpenAI API response")
+	}
+	var obj json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(content[start:])).Decode(&obj); err != nil {
+		return "", fmt.Errorf("failed to extract JSON object from OpenAI API response: %w", err)
+	}
+	return string(obj), nil
+}
+
+// 問い合わせ本文の言語を文字種から簡易判定する関数
+// ひらがな・カタカナ・漢字が一定割合含まれていれば ja、英字が主体なら en を返す
+// 判定できない場合は ja を返す
+func detectLanguage(text string) string {
+	var ja, latin int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han):
+			ja++
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin++
+		}
+	}
+	if ja == 0 && latin > 0 {
+		return "en"
+	}
+	// 英文中の固有名詞などを考慮し、日本語の文字が少ない場合は英語とみなす
+	if latin > 0 && ja*5 < latin {
+		return "en"
+	}
+	return "ja"
+}
+
+// IsSupportedLanguage は応答言語として対応している言語かを判定する
+func IsSupportedLanguage(lang string) bool {
+	return lang == "ja" || lang == "en"
+}
+
+// 出力言語を決定する関数
+// 呼び出し元で指定された言語、SUMMARY_LANGUAGE、問い合わせ本文からの判定の順に優先する
+func (h *OpenAI) resolveLanguage(query, lang string) string {
+	if lang != "" {
+		return lang
+	}
+	if h.cfg.SummaryLanguage != "" {
+		return h.cfg.SummaryLanguage
+	}
+	return detectLanguage(query)
+}
+
+// 判定した言語に合わせた出力言語の指示を返す関数
+func outputLanguageInstruction(lang string) string {
+	switch lang {
+	case "en":
+		return `## Output language
+The inquiry is written in English. Write your entire response in English.`
+	default:
+		return `## 出力言語
+日本語で回答してください。`
+	}
+}
+
+func resolutionStatus(resolved bool) string {
+	if resolved {
+		return "解決済み"
+	}
+	return "未解決"
+}
+
+func reporterOrUnknown(reporter string) string {
+	if reporter == "" {
+		return "不明"
+	}
+	return reporter
+}
+
+// 同じユーザーの直前の問い合わせをプロンプトに含めるセクションを返す。文脈が無い場合は空を返す
+func conversationSection(history string) string {
+	if history == "" {
+		return ""
+	}
+	return fmt.Sprintf(`## 直前の文脈
+同じユーザーによる直前の問い合わせと、その際に選定された課題です。今回の問い合わせが「さっきの」「それ」など前回を前提とした表現の場合に補って解釈してください。関係の無い場合は無視してください。
+
+%s
+`, history)
+}
+
+// 問い合わせを受けたチャンネルのトピック・説明を検索のヒントとして渡すプロンプトの節
+func channelContextSection(hint string) string {
+	if hint == "" {
+		return ""
+	}
+	return fmt.Sprintf(`## チャンネルの情報
+問い合わせを受けたSlackチャンネルのトピック・説明です。検索対象のプロジェクトやキーワードのヒントとして使ってください。問い合わせ内容と関係の無い場合は無視してください。
+
+%s
+`, hint)
+}
+
+// Slackスレッドのメンションの変換形式の説明
+const mentionFormatInstruction = `## メンション形式について：
 Slackスレッドに含まれるメンションは以下の形式で変換されています：
 - 【ユーザー】＠名前 : 個人ユーザーへのメンション（例：【ユーザー】＠田中太郎）
 - 【グループ】＠名前 : グループやチームへのメンション（例：【グループ】＠developers、【グループ】＠here）
 - ＠ID : 変換できなかったメンション（例：＠U083Z7J2FGX）
 
 これらの情報を参考に、課題に関わった担当者やチームを正確に識別してください。
+`
+
+// 要約生成のプロンプトを組み立てる
+func (h *OpenAI) summaryPrompt(query, lang, history string, issue *model.Result) string {
+	return fmt.Sprintf(`## 依頼内容
+以下のJiraの課題の内容と、その課題の解決方法(主にコメントとして記載されている)の結果をサマリとして自然言語で返答してください。
+あなたが作成した結果の用途は新しく課題をjiraに作成するかどうかを判断するためなので簡潔に類似かどうか判断できる材料をください。
+この課題のJira上の解決状態は「%s」です。コメントの内容から推測せず、解決状態はこの値の通りに書いてください。
+よくわからないことはよくわからないと書いてください。
 
+%s
 ## フォーマットの指定：
-- 課題の概要を300文字
-- 課題の解決結果を300文字
-- この課題に関連する担当者やチーム情報（上記のメンション形式を参考に、個人とグループを区別して記載）。特定できない場合は、特定できない旨を書いてください。
+結果は以下のフィールドを持つjson形式で出力してください。
+- overview: 課題の概要を%d文字
+- resolution: 課題の解決結果を%d文字
+- assignees: この課題に関連する担当者やチーム情報（上記のメンション形式を参考に、個人とグループを区別して記載）。特定できない場合は、特定できない旨を書いてください。課題の報告者（%s）も相談相手の候補として含めてください。
+- unresolved: 課題が未解決の場合は true、解決済みの場合は false（boolean）
 
 ## 過去に作成された課題
 %s
 
 ## 関連するSlackのスレッド
-%s`, issue.ContentSummary, issue.SlackThread)
+%s
+
+%s
+%s`, resolutionStatus(issue.Resolved), mentionFormatInstruction, h.cfg.SummaryOverviewChars, h.cfg.SummaryResolutionChars, reporterOrUnknown(issue.Reporter), issue.ContentSummary, issue.SlackThread, conversationSection(history), outputLanguageInstruction(h.resolveLanguage(query, lang)))
+}
+
+// GenerateSummaryForIssue は単一のIssueに対して要約を生成する（goroutine対応・retry機能付き）
+// 要約の言語は lang で指定された言語、未指定の場合は既定の言語か問い合わせ本文の言語に合わせる
+// history は同じユーザーの直前の問い合わせ（無い場合は空）
+func (h *OpenAI) GenerateSummaryForIssue(ctx context.Context, query, lang, history string, issue *model.Result) error {
+	prompt := h.summaryPrompt(query, lang, history, issue)
+	// retry機能付きで要約生成を実行
+	return retry.Retry(3, 3*time.Second, func() error {
+		content, err := h.completeJSON(ctx, prompt)
+		if err != nil {
+			return err
+		}
+		setSummary(issue, content)
+		return nil
+	})
+}
 
-		response, err := h.client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
+// GenerateSummaryStream はストリーミングAPIで要約を生成し、生成途中の全文を onChunk に渡す
+// 生成途中のJSONから取り出せた項目を onChunk に渡す
+// ストリーミングに対応していないモデルの場合は GenerateSummaryForIssue による一括生成にフォールバックする
+func (h *OpenAI) GenerateSummaryStream(ctx context.Context, query, lang, history string, issue *model.Result, onChunk func(model.SummaryFields)) error {
+	// レスポンスキャッシュ使用時は決定的な結果を得るため、キャッシュを通る一括生成を使う
+	if h.streamUnsupported.Load() || h.responseCache != nil {
+		return h.GenerateSummaryForIssue(ctx, query, lang, history, issue)
+	}
+
+	// ストリーミングではresponse_formatを使わずプロンプトでJSONを指示する
+	prompt := h.summaryPrompt(query, lang, history, issue) + "\n\n説明やコードブロックを含めず、JSONオブジェクトのみを返してください。"
+	var fallback bool
+	err := retry.Retry(3, 3*time.Second, func() error {
+		stream := h.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
 			Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
 				openai.UserMessage(prompt),
 			}),
-			Model: openai.F(os.Getenv("OPENAI_MODEL")),
+			Model: openai.F(h.cfg.Model),
 		})
-
-		if err != nil {
-			return fmt.Errorf("failed to call OpenAI API: %w", err)
+		defer stream.Close()
+
+		var content strings.Builder
+		for stream.Next() {
+			chunk := stream.Current()
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			if chunk.Choices[0].FinishReason == openai.ChatCompletionChunkChoicesFinishReasonContentFilter {
+				slog.WarnContext(ctx, "OpenAI response was blocked by content filter", slog.String("model", chunk.Model))
+				return fmt.Errorf("OpenAI response was blocked by content filter")
+			}
+			if delta := chunk.Choices[0].Delta.Content; delta != "" {
+				content.WriteString(delta)
+				onChunk(partialSummaryFields(content.String()))
+			}
 		}
-
-		issue.GeneratedSummary = response.Choices[0].Message.Content
+		if err := stream.Err(); err != nil {
+			if content.Len() == 0 && isStreamUnsupportedError(err) {
+				slog.WarnContext(ctx, "Streaming is not supported by the model, fallback to non-streaming", slog.Any("err", err))
+				h.streamUnsupported.Store(true)
+				fallback = true
+				return nil
+			}
+			// ストリームを開始できなかった場合は一括生成に切り替え、フォールバック先のクライアントやリトライはそちらに任せる
+			if content.Len() == 0 && !errors.Is(err, context.Canceled) {
+				slog.WarnContext(ctx, "LLM streaming failed to start, fallback to non-streaming", slog.Any("err", err))
+				fallback = true
+				return nil
+			}
+			return fmt.Errorf("failed to call OpenAI streaming API: %w", err)
+		}
+		if content.Len() == 0 {
+			return fmt.Errorf("OpenAI API returned empty content")
+		}
+		h.dump(h.cfg.Model, prompt, content.String())
+		setSummary(issue, content.String())
 		return nil
 	})
+	if fallback {
+		return h.GenerateSummaryForIssue(ctx, query, lang, history, issue)
+	}
+	return err
+}
+
+// stream パラメータが非対応であることによるエラーかを判定する
+func isStreamUnsupportedError(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return apiErr.Param == "stream" || strings.Contains(apiErr.Message, "stream")
+}
+
+// CreateEmbedding は本文のembeddingを取得する
+// OPENAI_EMBEDDING_MODEL（未指定時は text-embedding-3-small）を使用し、同じ本文の結果はキャッシュから返す
+func (h *OpenAI) CreateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	model := h.cfg.EmbeddingModel
+	key := embeddingCacheKey(model, text)
+	if embedding, ok := h.embeddingCache.Get(key); ok {
+		return embedding, nil
+	}
+
+	response, err := withFallback(ctx, h, func(client *openai.Client) (*openai.CreateEmbeddingResponse, error) {
+		return client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+			Input: openai.F[openai.EmbeddingNewParamsInputUnion](openai.EmbeddingNewParamsInputArrayOfStrings{text}),
+			Model: openai.F(model),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call OpenAI embeddings API: %w", err)
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("OpenAI embeddings API returned no data")
+	}
+	embedding := response.Data[0].Embedding
+	h.embeddingCache.Set(key, embedding)
+	return embedding, nil
+}
+
+// CompareIssues は問い合わせで指定された複数の課題の違いを比較した文章を生成する
+func (h *OpenAI) CompareIssues(ctx context.Context, query, lang string, issues []model.Result) (string, error) {
+	var sections []string
+	for _, issue := range issues {
+		sections = append(sections, fmt.Sprintf("## %s %s\n%s", issue.Key, issue.Summary, issue.GeneratedSummary))
+	}
+
+	prompt := fmt.Sprintf(`## 依頼内容
+以下の問い合わせで指定された複数のJira課題について、問い合わせの意図に沿って共通点と相違点を比較してください。
+要約に書かれていないことは推測せず、よくわからないことはよくわからないと書いてください。
+
+## 問い合わせ内容
+%s
+
+## 課題の要約
+%s
+
+%s`, query, strings.Join(sections, "\n\n"), outputLanguageInstruction(h.resolveLanguage(query, lang)))
+
+	return h.complete(ctx, prompt, false)
+}
+
+// 課題の内容を類似度計算用の短い要点に圧縮する関数
+// 圧縮結果は内容のハッシュをキーにキャッシュする
+func (h *OpenAI) CondenseIssue(ctx context.Context, contentSummary string) (string, error) {
+	sum := sha256.Sum256([]byte(contentSummary))
+	cacheKey := hex.EncodeToString(sum[:])
+	if condensed := h.condenseCache.Get(cacheKey); condensed != nil {
+		return condensed.Value(), nil
+	}
+
+	prompt := fmt.Sprintf(`以下のJiraの課題を、類似する問い合わせかどうかを判断するための要点に圧縮してください。
+
+要件:
+- 発生している症状・エラー、対象の機能・システム、解決状況を含める
+- 箇条書きで200文字以内
+- 要点以外の説明は出力しない
+
+## 課題
+%s`, contentSummary)
+
+	model := h.cfg.CondenseModel
+	responseKey := llmResponseCacheKey(model, false, prompt)
+	if condensed, ok := h.responseCache.get(responseKey); ok {
+		h.condenseCache.Set(cacheKey, condensed, ttlcache.DefaultTTL)
+		return condensed, nil
+	}
+
+	response, err := withFallback(ctx, h, func(client *openai.Client) (*openai.ChatCompletion, error) {
+		return client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+				openai.UserMessage(prompt),
+			}),
+			Model: openai.F(model),
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to call OpenAI API: %w", err)
+	}
+
+	condensed, err := firstChoiceContent(response)
+	if err != nil {
+		return "", err
+	}
+	h.dump(model, prompt, condensed)
+	h.responseCache.set(responseKey, model, condensed)
+	h.condenseCache.Set(cacheKey, condensed, ttlcache.DefaultTTL)
+	return condensed, nil
 }
 
-// Jiraの検索クエリを生成する関数
-func (h *OpenAI) GenerateJiraQuery(query string, lastError error) (string, error) {
+// 判定した言語に合わせた検索キーワードの指示を返す関数
+func queryLanguageInstruction(lang string) string {
+	switch lang {
+	case "en":
+		return `## Keyword language
+The inquiry is written in English. Choose search keywords in English.`
+	default:
+		return `## キーワードの言語
+問い合わせは日本語で書かれています。検索キーワードは日本語で選んでください。`
+	}
+}
+
+// 日本語と英語のどちらで書かれた課題も取りこぼさないよう、両方のキーワードでJQLを組む指示
+const bilingualKeywordInstruction = `## キーワードの言語
+課題は日本語と英語のどちらで書かれている場合もあります。
+各キーワードについて日本語と英語の両方の候補を挙げ、text ~ "ログイン" OR text ~ "login" のように両方を OR で含むJQLにしてください。
+製品名・機能名などカタカナ語は英語表記も候補に含めてください。`
+
+// BILINGUAL_KEYWORDS が有効な場合は日英両方のキーワードを使う指示を返す
+func (h *OpenAI) jiraQueryLanguageInstruction(query string) string {
+	if h.cfg.BilingualKeywords {
+		return bilingualKeywordInstruction
+	}
+	return queryLanguageInstruction(detectLanguage(query))
+}
+
+// Jiraの検索クエリを観点を変えて複数生成する関数
+// history は同じユーザーの直前の問い合わせ、channelHint はチャンネルのトピック・説明（無い場合はそれぞれ空）
+func (h *OpenAI) GenerateJiraQueries(ctx context.Context, query, history, channelHint string, lastError error) ([]string, error) {
 	// OpenAI APIを呼び出してJira検索クエリを生成
 	prompt := fmt.Sprintf(`以下の問い合わせ内容に関連するJira課題を検索するクエリを生成してください。
 
@@ -119,48 +584,144 @@ func (h *OpenAI) GenerateJiraQuery(query string, lastError error) (string, error
 要件:
 - 関連性の高い課題を効率的に検索できること
 - 2-4個の適切なキーワードを組み合わせる
-- 結果はjson形式でsearch_queryフィールドに出力
+- 取りこぼしを防ぐため、観点の異なるクエリを1-%d個生成する
+- 結果はjson形式でsearch_queryフィールドに文字列の配列として出力
 
 %s
 
-前回のエラー: %s
-
+%s
+%s
+%s
 問い合わせ内容:
+%s
+
 %s`,
-		os.Getenv("JIRA_PROJECT_KEY"),
-		os.Getenv("JIRA_SEARCH_QUERY"),
-		lastError,
-		query)
+		h.jira.ProjectKey,
+		maxJiraQueries,
+		h.jira.SearchQuery,
+		retryInstruction(lastError),
+		conversationSection(history),
+		channelContextSection(channelHint),
+		query,
+		h.jiraQueryLanguageInstruction(query))
+
+	content, err := h.completeJSON(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
 
-	response, err := h.client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F(os.Getenv("OPENAI_MODEL")),
-		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
-			openai.ResponseFormatJSONObjectParam{
-				Type: openai.F(openai.ResponseFormatJSONObjectTypeJSONObject),
-			},
-		),
-	})
+	queries, err := parseSearchQueries(content)
+	if err != nil {
+		return nil, err
+	}
+	// キーワードが少なすぎる緩いクエリはノイズが増えるため、問い合わせから機械的に補う
+	for i, q := range queries {
+		if supplemented := ensureMinKeywords(q, query, h.cfg.MinKeywords); supplemented != q {
+			slog.InfoContext(ctx, "Supplemented Jira query keywords", slog.String("before", q), slog.String("after", supplemented))
+			queries[i] = supplemented
+		}
+	}
+	slog.InfoContext(ctx, "Jira検索クエリ", slog.Any("search_query", queries))
+	return queries, nil
+}
+
+// 問い合わせからJira検索用のキーワードを抽出する関数
+// JQL_TEMPLATE を使う場合に、JQL全体ではなくキーワードのみをLLMに選ばせる
+func (h *OpenAI) ExtractKeywords(ctx context.Context, query, history, channelHint string, lastError error) ([]string, error) {
+	prompt := fmt.Sprintf(`以下の問い合わせ内容に関連するJira課題を全文検索するためのキーワードを抽出してください。
 
+要件:
+- 課題の特定に役立つ2-4個のキーワードを選ぶ
+- 一般的すぎる語（問題、エラー、お願いなど）は避ける
+- 結果はjson形式でkeywordsフィールドに文字列の配列として出力
+
+%s
+%s
+%s
+問い合わせ内容:
+%s
+
+%s`,
+		retryInstruction(lastError),
+		conversationSection(history),
+		channelContextSection(channelHint),
+		query,
+		queryLanguageInstruction(detectLanguage(query)))
+
+	content, err := h.completeJSON(ctx, prompt)
 	if err != nil {
-		return "", fmt.Errorf("failed to call OpenAI API: %w", err)
+		return nil, err
 	}
 
+	var result struct {
+		Keywords []string `json:"keywords"`
+	}
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAI API response: %w", err)
+	}
+	var keywords []string
+	for _, k := range result.Keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			keywords = append(keywords, k)
+		}
+	}
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("OpenAI API returned no keywords")
+	}
+	keywords = supplementKeywords(keywords, query, h.cfg.MinKeywords)
+	slog.InfoContext(ctx, "Jira検索キーワード", slog.Any("keywords", keywords))
+	return keywords, nil
+}
+
+// search_query を配列・文字列のどちらで返されても受け付け、重複と空を除いて返す
+func parseSearchQueries(content string) ([]string, error) {
 	var searchQuery struct {
-		SearchQuery string `json:"search_query"`
+		SearchQuery json.RawMessage `json:"search_query"`
 	}
-	err = json.Unmarshal([]byte(response.Choices[0].Message.Content), &searchQuery)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse OpenAI API response: %w", err)
+	if err := json.Unmarshal([]byte(content), &searchQuery); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAI API response: %w", err)
+	}
+
+	var candidates []string
+	if err := json.Unmarshal(searchQuery.SearchQuery, &candidates); err != nil {
+		var single string
+		if err := json.Unmarshal(searchQuery.SearchQuery, &single); err != nil {
+			return nil, fmt.Errorf("failed to parse search_query: %w", err)
+		}
+		candidates = []string{single}
+	}
+
+	seen := make(map[string]bool)
+	var queries []string
+	for _, q := range candidates {
+		q = strings.TrimSpace(q)
+		if q == "" || seen[q] {
+			continue
+		}
+		seen[q] = true
+		queries = append(queries, q)
+		if len(queries) == maxJiraQueries {
+			break
+		}
 	}
-	slog.Info("Jira検索クエリ", slog.String("search_query", searchQuery.SearchQuery))
-	return searchQuery.SearchQuery, nil
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("OpenAI API returned no search query")
+	}
+	return queries, nil
 }
 
 // 問い合わせとjiraの関連度を算出する関数
-func (h *OpenAI) CalculateSimilarity(query, contentSummary, slackThreadMessages string) (float64, error) {
+// 課題本文とSlackスレッドがほぼ空の場合はAPIを呼ばずに0を返す
+// issueKey はログの相関に使う
+func (h *OpenAI) CalculateSimilarity(ctx context.Context, issueKey, query, contentSummary, slackThreadMessages string) (float64, error) {
+	if length := contentLength(contentSummary) + contentLength(slackThreadMessages); length < h.cfg.SimilarityMinContentChars {
+		slog.InfoContext(ctx, "Skip similarity calculation for empty content",
+			slog.String("issue_key", issueKey),
+			slog.Int("content_chars", length),
+			slog.Int64("skipped", h.similaritySkipped.Add(1)))
+		return 0, nil
+	}
+
 	// 各Jira問い合わせの内容をOpenAIに送り、関連度を算出
 	prompt := fmt.Sprintf(`以下の2つの課題内容の類似度を0.0-1.0で評価してください。
 
@@ -169,7 +730,7 @@ func (h *OpenAI) CalculateSimilarity(query, contentSummary, slackThreadMessages
 - 0.5以上: 類似の症状・エラーが発生
 - 0.7以上: ほぼ同じ問題
 
-カスタマーサービスの観点で、ユーザーからの問い合わせの類似性を重視してください。
+%s
 
 新しい課題:
 %s
@@ -180,30 +741,60 @@ func (h *OpenAI) CalculateSimilarity(query, contentSummary, slackThreadMessages
 Slackスレッド:
 %s
 
-結果をjsonのsimilarityフィールド（float型）で返してください。`, query, contentSummary, slackThreadMessages)
-
-	response, err := h.client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F(os.Getenv("OPENAI_MODEL")),
-		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
-			openai.ResponseFormatJSONObjectParam{
-				Type: openai.F(openai.ResponseFormatJSONObjectTypeJSONObject),
-			},
-		),
-	})
+結果をjsonのsimilarityフィールド（float型）で返してください。`, h.similarityCriteria(), query, contentSummary, slackThreadMessages)
 
+	content, err := h.completeJSON(ctx, prompt)
 	if err != nil {
+		slog.WarnContext(ctx, "Failed to calculate similarity",
+			slog.String("issue_key", issueKey),
+			slog.String("model", h.cfg.Model),
+			slog.Int("prompt_chars", utf8.RuneCountInString(prompt)),
+			slog.Any("err", err))
 		return 0, err
 	}
 
 	var similarity struct {
 		Similarity float64 `json:"similarity"`
 	}
-	err = json.Unmarshal([]byte(response.Choices[0].Message.Content), &similarity)
+	err = json.Unmarshal([]byte(content), &similarity)
 	if err != nil {
+		slog.WarnContext(ctx, "Failed to parse similarity response",
+			slog.String("issue_key", issueKey),
+			slog.Int("response_chars", utf8.RuneCountInString(content)),
+			slog.Any("err", err))
 		return 0, fmt.Errorf("failed to parse OpenAI API response: %w", err)
 	}
-	return similarity.Similarity, nil
+	return clampSimilarity(ctx, issueKey, similarity.Similarity), nil
+}
+
+// SIMILARITY_CRITERIA 未設定時の類似度判定の観点
+const defaultSimilarityCriteria = "カスタマーサービスの観点で、ユーザーからの問い合わせの類似性を重視してください。"
+
+// 類似度判定の観点を返す
+func (h *OpenAI) similarityCriteria() string {
+	if h.cfg.SimilarityCriteria != "" {
+		return h.cfg.SimilarityCriteria
+	}
+	return defaultSimilarityCriteria
+}
+
+// モデルが範囲外の値を返すことがあるため 0.0-1.0 に収める
+// NaN・Inf は評価できなかったものとして 0 にする
+func clampSimilarity(ctx context.Context, issueKey string, v float64) float64 {
+	clamped := v
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0):
+		clamped = 0
+	case v < 0:
+		clamped = 0
+	case v > 1:
+		clamped = 1
+	default:
+		return v
+	}
+	slog.WarnContext(ctx, "Similarity out of range, clamped",
+		slog.String("issue_key", issueKey),
+		slog.Float64("similarity", v),
+		slog.Float64("clamped", clamped))
+	return clamped
 }
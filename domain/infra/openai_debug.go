@@ -0,0 +1,30 @@
+package infra
+
+import (
+	"log/slog"
+	"unicode/utf8"
+)
+
+// ダンプするプロンプト・レスポンスの最大文字数
+const debugDumpMaxChars = 4000
+
+// OPENAI_DEBUG_DUMP が有効な場合に、送信したプロンプトと受信したレスポンスを出力する
+func (h *OpenAI) dump(model, prompt, response string) {
+	if !h.cfg.DebugDump {
+		return
+	}
+	slog.Debug("OpenAI request/response",
+		slog.String("model", model),
+		slog.String("prompt", truncateForDump(prompt)),
+		slog.String("response", truncateForDump(response)),
+	)
+}
+
+// 長すぎるテキストを先頭から debugDumpMaxChars 文字に切り詰める
+func truncateForDump(text string) string {
+	if utf8.RuneCountInString(text) <= debugDumpMaxChars {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:debugDumpMaxChars]) + "...(truncated)"
+}
@@ -0,0 +1,43 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/openai/openai-go"
+)
+
+// プライマリのクライアントで呼び出し、失敗した場合はフォールバック先のクライアントで再試行する
+// リクエスト内容の誤りなどフォールバックしても解消しないエラーはそのまま返す
+func withFallback[T any](ctx context.Context, h *OpenAI, call func(client *openai.Client) (T, error)) (T, error) {
+	res, err := call(h.client)
+	if err == nil || h.fallbackClient == nil || !isFallbackError(err) {
+		return res, err
+	}
+	slog.WarnContext(ctx, "Primary LLM call failed, retry with fallback",
+		slog.String("primary", h.cfg.Primary),
+		slog.String("fallback", h.cfg.Fallback),
+		slog.Any("err", err))
+	return call(h.fallbackClient)
+}
+
+// クォータ超過・障害・認証エラー・通信エラーなど、プロバイダを切り替えれば成功しうるエラーかを判定する
+func isFallbackError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusTooManyRequests,
+		apiErr.StatusCode == http.StatusUnauthorized,
+		apiErr.StatusCode == http.StatusForbidden,
+		apiErr.StatusCode >= http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package infra
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// レート制限による再試行の回数と、ヘッダから待機時間がわからない場合の待機時間の初期値・上限
+const (
+	rateLimitMaxRetries = 3
+	rateLimitBaseDelay  = 2 * time.Second
+	rateLimitMaxDelay   = time.Minute
+)
+
+// 429 のレスポンスを受けた場合に x-ratelimit-* ヘッダから待機時間を推定して再試行するミドルウェア
+// リクエスト数(RPM)とトークン数(TPM)のどちらの上限に達したかで回復までの時間が異なるため、残量が0の方のリセット時間まで待つ
+func rateLimitMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := next(req)
+		if err != nil || res.StatusCode != http.StatusTooManyRequests || attempt >= rateLimitMaxRetries {
+			return res, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			// リクエストボディを再送できない場合は再試行しない
+			return res, err
+		}
+
+		delay, reason := rateLimitDelay(res.Header, attempt)
+		slog.Warn("OpenAI rate limit exceeded, waiting before retry",
+			slog.String("reason", reason),
+			slog.Duration("delay", delay),
+			slog.Int("attempt", attempt+1),
+			slog.String("remaining_requests", res.Header.Get("x-ratelimit-remaining-requests")),
+			slog.String("remaining_tokens", res.Header.Get("x-ratelimit-remaining-tokens")))
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// レート制限のヘッダから待機時間と待機理由を求める
+// ヘッダが無い場合は指数バックオフにする
+func rateLimitDelay(header http.Header, attempt int) (time.Duration, string) {
+	var delay time.Duration
+	var reason string
+	if header.Get("x-ratelimit-remaining-requests") == "0" {
+		if d, err := time.ParseDuration(header.Get("x-ratelimit-reset-requests")); err == nil && d > delay {
+			delay, reason = d, "requests per minute (RPM)"
+		}
+	}
+	if header.Get("x-ratelimit-remaining-tokens") == "0" {
+		if d, err := time.ParseDuration(header.Get("x-ratelimit-reset-tokens")); err == nil && d > delay {
+			delay, reason = d, "tokens per minute (TPM)"
+		}
+	}
+	if delay == 0 {
+		if sec, err := strconv.ParseFloat(header.Get("retry-after"), 64); err == nil && sec > 0 {
+			delay, reason = time.Duration(sec*float64(time.Second)), "retry-after header"
+		}
+	}
+	if delay == 0 {
+		delay, reason = rateLimitBaseDelay<<attempt, "exponential backoff (no rate limit headers)"
+	}
+	if delay > rateLimitMaxDelay {
+		delay = rateLimitMaxDelay
+	}
+	return delay, reason
+}
@@ -1,35 +1,90 @@
 package infra
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"net/url"
 	"strings"
+	"text/template"
 	"time"
 
 	ttlcache "github.com/jellydator/ttlcache/v3"
+	"github.com/pyama86/jipcy/config"
 	"github.com/pyama86/jipcy/domain/model"
 	"github.com/slack-go/slack"
 )
 
 type Slack struct {
-	client             *slack.Client
+	client *slack.Client
+	// リアクションなどBotとして行う操作に使うクライアント
+	botClient          *slack.Client
 	channelInfoCache   *ttlcache.Cache[string, *slack.Channel]
 	usersCache         *ttlcache.Cache[string, []slack.User]
 	userNameCache      *ttlcache.Cache[string, *slack.User]
 	groupsCache        *ttlcache.Cache[string, []slack.UserGroup]
 	userGroupNameCache *ttlcache.Cache[string, *slack.UserGroup]
+	userNameFormat     *template.Template
+	excludeBots        map[string]bool
+	// 検索対象とするチャンネルのIDと名前（空の場合は全チャンネル）
+	allowChannels map[string]bool
+	// 検索対象とするチャンネル名（空の場合は制限しない）
+	channel string
 }
 
-func NewSlack() *Slack {
-	api := slack.New(os.Getenv("SLACK_USER_TOKEN"))
+// USER_NAME_FORMAT テンプレートに渡すユーザー情報
+type userNameFields struct {
+	DisplayName string
+	RealName    string
+	Name        string
+	Email       string
+	Title       string
+	TimeZone    string
+}
+
+// FetchWorkspaceURL は team.info からワークスペースのURLを取得する
+func (h *Slack) FetchWorkspaceURL() (string, error) {
+	team, err := h.botClient.GetTeamInfo()
+	if err != nil {
+		return "", fmt.Errorf("failed to get team info: %w", err)
+	}
+	if team.Domain == "" {
+		return "", fmt.Errorf("team info has no domain")
+	}
+	return fmt.Sprintf("https://%s.slack.com", team.Domain), nil
+}
+
+func NewSlack(cfg *config.Config) *Slack {
+	api := slack.New(cfg.Slack.UserToken)
 	s := &Slack{
 		client:             api,
+		botClient:          slack.New(cfg.Slack.BotToken),
 		channelInfoCache:   ttlcache.New(ttlcache.WithTTL[string, *slack.Channel](time.Hour * 24)),
 		usersCache:         ttlcache.New(ttlcache.WithTTL[string, []slack.User](time.Hour)),
 		userNameCache:      ttlcache.New(ttlcache.WithTTL[string, *slack.User](time.Hour)),
 		groupsCache:        ttlcache.New(ttlcache.WithTTL[string, []slack.UserGroup](time.Hour)),
 		userGroupNameCache: ttlcache.New(ttlcache.WithTTL[string, *slack.UserGroup](time.Hour)),
+		excludeBots:        make(map[string]bool),
+		allowChannels:      make(map[string]bool),
+		channel:            cfg.Slack.Channel,
 	}
+	for _, id := range cfg.Slack.ExcludeBots {
+		s.excludeBots[id] = true
+	}
+	for _, ch := range cfg.Slack.SearchAllowChannels {
+		s.allowChannels[ch] = true
+	}
+	if format := cfg.Slack.UserNameFormat; format != "" {
+		tmpl, err := template.New("user_name").Parse(format)
+		if err != nil {
+			slog.Error("Failed to parse USER_NAME_FORMAT, fallback to default", slog.Any("err", err))
+		} else {
+			s.userNameFormat = tmpl
+		}
+	}
+
 	go s.channelInfoCache.Start()
 	go s.usersCache.Start()
 	go s.userNameCache.Start()
@@ -51,9 +106,12 @@ func NewSlack() *Slack {
 	return s
 }
 
-func (h *Slack) FormattedSearchThreads(threads []model.ThreadMessage) (string, error) {
+func (h *Slack) FormattedSearchThreads(ctx context.Context, threads []model.ThreadMessage) (string, error) {
 	var formattedThreads []string
 	for _, thread := range threads {
+		if err := ctx.Err(); err != nil {
+			return "", fmt.Errorf("format threads canceled: %w", err)
+		}
 		// ユーザーIDを表示名に変換
 		userName := thread.User
 		if user, err := h.GetUserByID(thread.User); err == nil {
@@ -71,13 +129,56 @@ func (h *Slack) FormattedSearchThreads(threads []model.ThreadMessage) (string, e
 	return strings.Join(formattedThreads, "\n"), nil
 }
 
-func (h *Slack) SearchThreads(keyword, channelID string) ([]model.ThreadMessage, error) {
-	if os.Getenv("SLACK_CHANNEL") != "" {
-		slackChannel := strings.TrimPrefix(os.Getenv("SLACK_CHANNEL"), "#")
-		keyword = fmt.Sprintf("in:#%s %s", slackChannel, keyword)
+func (h *Slack) SearchThreads(ctx context.Context, keyword, channelID string) ([]model.ThreadMessage, error) {
+	matches, err := h.searchMessages(ctx, keyword)
+	if err != nil {
+		return nil, err
+	}
+	return h.collectThreads(ctx, matches, 0)
+}
+
+// SearchThreadsByKeywords は複数のキーワードでスレッドを検索する
+// matchAll が true の場合は全キーワードを含むメッセージ(AND)、false の場合はいずれかを含むメッセージ(OR)を対象とする
+// maxThreads が1以上の場合は取得するスレッド数の上限とする
+func (h *Slack) SearchThreadsByKeywords(ctx context.Context, keywords []string, matchAll bool, channelID string, maxThreads int) ([]model.ThreadMessage, error) {
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+
+	var matches []slack.SearchMessage
+	if matchAll {
+		m, err := h.searchMessages(ctx, strings.Join(keywords, " "))
+		if err != nil {
+			return nil, err
+		}
+		matches = m
+	} else {
+		// Slack検索はOR演算子に対応していないため、キーワードごとに検索して結果をまとめる
+		seen := make(map[string]bool)
+		for _, keyword := range keywords {
+			m, err := h.searchMessages(ctx, keyword)
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range m {
+				key := match.Channel.ID + ":" + match.Timestamp
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				matches = append(matches, match)
+			}
+		}
+	}
+	return h.collectThreads(ctx, matches, maxThreads)
+}
+
+func (h *Slack) searchMessages(ctx context.Context, keyword string) ([]slack.SearchMessage, error) {
+	if h.channel != "" {
+		keyword = fmt.Sprintf("in:#%s %s", h.channel, keyword)
 	}
 
-	searchResult, err := h.client.SearchMessages(keyword, slack.SearchParameters{
+	searchResult, err := h.client.SearchMessagesContext(ctx, keyword, slack.SearchParameters{
 		Count:         10,
 		Sort:          "timestamp",
 		SortDirection: "asc",
@@ -85,60 +186,169 @@ func (h *Slack) SearchThreads(keyword, channelID string) ([]model.ThreadMessage,
 	if err != nil {
 		return nil, fmt.Errorf("failed to search messages keyword:%s error: %w", keyword, err)
 	}
+	return searchResult.Matches, nil
+}
 
-	visitedThreads := make(map[string]bool)
-	var allThreadMessages []model.ThreadMessage
+// 検索にマッチしたメッセージが属するスレッド
+type threadRef struct {
+	channelID string
+	parentTS  string
+}
+
+func (r threadRef) key() string {
+	return r.channelID + ":" + r.parentTS
+}
+
+// マッチのパーマリンクから親メッセージのタイムスタンプを求める
+// スレッドの返信のパーマリンクには thread_ts が付くため、付いていなければマッチ自身が親となる
+// パーマリンクが無い・解析できない場合は false を返す
+func permalinkParentTS(match slack.SearchMessage) (string, bool) {
+	if match.Permalink == "" {
+		return "", false
+	}
+	u, err := url.Parse(match.Permalink)
+	if err != nil {
+		return "", false
+	}
+	if ts := u.Query().Get("thread_ts"); ts != "" {
+		return ts, true
+	}
+	return match.Timestamp, true
+}
 
-	for _, match := range searchResult.Matches {
-		channelID := match.Channel.ID
-		history, err := h.client.GetConversationHistory(&slack.GetConversationHistoryParameters{
+// 履歴から親メッセージのタイムスタンプを求める関数（メッセージが見つからない場合は false）
+type parentLookup func(channelID, ts string) (string, bool, error)
+
+// threadRefsFromMatches は検索のマッチを属するスレッドへの参照に変換し、出現順に重複を除いて返す
+// パーマリンクから親を求められないマッチのみ lookup で履歴を引く
+// 他のマッチから親と判明しているメッセージと、同じチャンネル・タイムスタンプのマッチは再度引かない
+func threadRefsFromMatches(matches []slack.SearchMessage, allowed func(slack.CtxChannel) bool, lookup parentLookup) ([]threadRef, error) {
+	// マッチごとの参照（解決できなかったものは空）
+	resolved := make([]threadRef, len(matches))
+	known := make(map[string]bool)
+	var pending []int
+	for i, match := range matches {
+		if !allowed(match.Channel) {
+			continue
+		}
+		if parentTS, ok := permalinkParentTS(match); ok {
+			resolved[i] = threadRef{channelID: match.Channel.ID, parentTS: parentTS}
+			known[resolved[i].key()] = true
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	looked := make(map[string]threadRef)
+	for _, i := range pending {
+		self := threadRef{channelID: matches[i].Channel.ID, parentTS: matches[i].Timestamp}
+		if known[self.key()] {
+			// 他のマッチのスレッドの親メッセージそのもの
+			resolved[i] = self
+			continue
+		}
+		if ref, ok := looked[self.key()]; ok {
+			resolved[i] = ref
+			continue
+		}
+		parentTS, ok, err := lookup(self.channelID, self.parentTS)
+		if err != nil {
+			return nil, err
+		}
+		var ref threadRef
+		if ok {
+			ref = threadRef{channelID: self.channelID, parentTS: parentTS}
+			known[ref.key()] = true
+		}
+		looked[self.key()] = ref
+		resolved[i] = ref
+	}
+
+	seen := make(map[string]bool, len(resolved))
+	refs := make([]threadRef, 0, len(resolved))
+	for _, ref := range resolved {
+		if ref.parentTS == "" || seen[ref.key()] {
+			continue
+		}
+		seen[ref.key()] = true
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// マッチの親メッセージを解決する
+// パーマリンクから求められない場合のみ履歴を取得する
+func (h *Slack) resolveThreadRefs(ctx context.Context, matches []slack.SearchMessage) ([]threadRef, error) {
+	allowed := func(channel slack.CtxChannel) bool {
+		if h.isAllowedChannel(channel) {
+			return true
+		}
+		slog.DebugContext(ctx, "Skip search result in not allowed channel",
+			slog.String("channel", channel.ID),
+			slog.String("name", channel.Name))
+		return false
+	}
+	return threadRefsFromMatches(matches, allowed, func(channelID, ts string) (string, bool, error) {
+		history, err := h.client.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
 			ChannelID: channelID,
 			Inclusive: true,
-			Latest:    match.Timestamp,
+			Latest:    ts,
 			Limit:     1,
-			Oldest:    match.Timestamp,
+			Oldest:    ts,
 		})
 		if err != nil {
-			return nil, fmt.Errorf("メッセージ履歴取得に失敗しました (channel=%s, ts=%s): %w",
-				channelID, match.Timestamp, err)
+			return "", false, fmt.Errorf("メッセージ履歴取得に失敗しました (channel=%s, ts=%s): %w",
+				channelID, ts, err)
 		}
 		if len(history.Messages) == 0 {
-			continue
+			return "", false, nil
 		}
 
 		parentMsg := history.Messages[0]
-		var parentTS string
 		// スレッドの場合は親メッセージのタイムスタンプを取得
 		if parentMsg.ThreadTimestamp != "" {
-			parentTS = parentMsg.ThreadTimestamp
-		} else {
-			parentTS = parentMsg.Timestamp
+			return parentMsg.ThreadTimestamp, true, nil
 		}
+		return parentMsg.Timestamp, true, nil
+	})
+}
 
-		threadKey := channelID + ":" + parentTS
-		if visitedThreads[threadKey] {
-			continue
-		}
-		visitedThreads[threadKey] = true
+// 検索にヒットしたメッセージが属するスレッドのメッセージを取得する
+// maxThreads が1以上の場合はその数のスレッドを取得した時点で打ち切る
+func (h *Slack) collectThreads(ctx context.Context, matches []slack.SearchMessage, maxThreads int) ([]model.ThreadMessage, error) {
+	refs, err := h.resolveThreadRefs(ctx, matches)
+	if err != nil {
+		return nil, err
+	}
+	if maxThreads > 0 && len(refs) > maxThreads {
+		refs = refs[:maxThreads]
+	}
+	slog.DebugContext(ctx, "Resolved search matches to threads", slog.Int("matches", len(matches)), slog.Int("threads", len(refs)))
 
-		replies, _, _, err := h.client.GetConversationReplies(&slack.GetConversationRepliesParameters{
-			ChannelID: channelID,
-			Timestamp: parentTS,
+	var allThreadMessages []model.ThreadMessage
+	for _, ref := range refs {
+		replies, _, _, err := h.client.GetConversationRepliesContext(ctx, &slack.GetConversationRepliesParameters{
+			ChannelID: ref.channelID,
+			Timestamp: ref.parentTS,
 			Inclusive: true,
 			Limit:     100,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("スレッド取得に失敗しました (channel=%s, parentTS=%s): %w",
-				channelID, parentTS, err)
+				ref.channelID, ref.parentTS, err)
 		}
 
 		for _, msg := range replies {
+			if h.isBotMessage(msg) {
+				continue
+			}
 			userName := msg.User
 			allThreadMessages = append(allThreadMessages, model.ThreadMessage{
-				ChannelID: channelID,
-				Timestamp: msg.Timestamp,
-				User:      userName,
-				Text:      msg.Text,
+				ChannelID:       ref.channelID,
+				Timestamp:       msg.Timestamp,
+				ThreadTimestamp: ref.parentTS,
+				User:            userName,
+				Text:            msg.Text,
 			})
 		}
 	}
@@ -146,6 +356,74 @@ func (h *Slack) SearchThreads(keyword, channelID string) ([]model.ThreadMessage,
 	return allThreadMessages, nil
 }
 
+// SLACK_SEARCH_ALLOW_CHANNELS が設定されている場合、列挙されたチャンネルかどうかを判定する
+// チャンネルIDと名前のどちらでも指定できる
+func (h *Slack) isAllowedChannel(channel slack.CtxChannel) bool {
+	if len(h.allowChannels) == 0 {
+		return true
+	}
+	return h.allowChannels[channel.ID] || h.allowChannels[channel.Name]
+}
+
+// 要約のノイズになるBotの投稿かどうかを判定する
+func (h *Slack) isBotMessage(msg slack.Message) bool {
+	if msg.SubType == "bot_message" || msg.BotID != "" {
+		return true
+	}
+	return h.excludeBots[msg.User]
+}
+
+// IsNotInChannelError はBotがチャンネルに参加していないことによるエラーかを判定する
+// channel_not_found はチャンネルの指定誤りやアクセス権が無い場合にも返るため含めない
+func IsNotInChannelError(err error) bool {
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		return slackErr.Err == "not_in_channel"
+	}
+	return err != nil && strings.Contains(err.Error(), "not_in_channel")
+}
+
+// BotIsMember はBotがチャンネルに参加しているかをBotトークンの conversations.info で判定する
+// 招待されるとすぐに応答できるよう結果はキャッシュしない
+func (h *Slack) BotIsMember(channelID string) (bool, error) {
+	channel, err := h.botClient.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+	if err != nil {
+		return false, fmt.Errorf("failed to get channel info with bot token: %w", err)
+	}
+	return channel.IsMember, nil
+}
+
+// GetThreadPermalink はスレッドのパーマリンクを返す
+// chat.getPermalink で取得できない場合はワークスペースURLから組み立てる
+func (h *Slack) GetThreadPermalink(ctx context.Context, workspaceURL string, msg model.ThreadMessage) string {
+	parentTS := msg.ThreadTimestamp
+	if parentTS == "" {
+		parentTS = msg.Timestamp
+	}
+	link, err := h.client.GetPermalinkContext(ctx, &slack.PermalinkParameters{
+		Channel: msg.ChannelID,
+		Ts:      parentTS,
+	})
+	if err == nil && link != "" {
+		return link
+	}
+	slog.WarnContext(ctx, "Failed to get permalink, fallback to building URL",
+		slog.String("channel", msg.ChannelID),
+		slog.String("ts", parentTS),
+		slog.Any("err", err))
+	return buildPermalink(workspaceURL, msg.ChannelID, msg.Timestamp, parentTS)
+}
+
+// Slackのパーマリンク形式のURLを組み立てる
+// タイムスタンプの "." を除去し、スレッド返信の場合は thread_ts を付与する
+func buildPermalink(workspaceURL, channelID, ts, threadTS string) string {
+	link := fmt.Sprintf("%s/archives/%s/p%s", strings.TrimSuffix(workspaceURL, "/"), channelID, strings.ReplaceAll(ts, ".", ""))
+	if threadTS != "" && threadTS != ts {
+		link += fmt.Sprintf("?thread_ts=%s&cid=%s", threadTS, channelID)
+	}
+	return link
+}
+
 func (h *Slack) GetChannelInfo(channelID string) (*slack.Channel, error) {
 	if channel := h.channelInfoCache.Get(channelID); channel != nil {
 		return channel.Value(), nil
@@ -196,6 +474,11 @@ func (h *Slack) GetUserByID(id string) (*slack.User, error) {
 }
 
 func (h *Slack) GetUserPreferredName(user *slack.User) string {
+	if h.userNameFormat != nil {
+		if name := h.formatUserName(user); name != "" {
+			return name
+		}
+	}
 	if user.Profile.DisplayName != "" {
 		return user.Profile.DisplayName
 	}
@@ -205,6 +488,24 @@ func (h *Slack) GetUserPreferredName(user *slack.User) string {
 	return user.Name
 }
 
+// USER_NAME_FORMAT で指定されたテンプレートで表示名を組み立てる
+func (h *Slack) formatUserName(user *slack.User) string {
+	var buf bytes.Buffer
+	err := h.userNameFormat.Execute(&buf, userNameFields{
+		DisplayName: user.Profile.DisplayName,
+		RealName:    user.RealName,
+		Name:        user.Name,
+		Email:       user.Profile.Email,
+		Title:       user.Profile.Title,
+		TimeZone:    user.TZ,
+	})
+	if err != nil {
+		slog.Error("Failed to execute USER_NAME_FORMAT", slog.String("user", user.ID), slog.Any("err", err))
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
 func (h *Slack) getUserGroups() ([]slack.UserGroup, error) {
 	cacheKey := "user_groups"
 	if groups := h.groupsCache.Get(cacheKey); groups != nil {
@@ -403,3 +704,19 @@ func (h *Slack) PostMessage(channelID, message string) error {
 	_, _, err := h.client.PostMessage(channelID, slack.MsgOptionText(message, false))
 	return err
 }
+
+// AddReaction はメッセージにBotとしてリアクションを付ける
+func (h *Slack) AddReaction(channelID, ts, name string) error {
+	if err := h.botClient.AddReaction(name, slack.NewRefToMessage(channelID, ts)); err != nil {
+		return fmt.Errorf("failed to add reaction %s: %w", name, err)
+	}
+	return nil
+}
+
+// RemoveReaction はBotが付けたリアクションを外す
+func (h *Slack) RemoveReaction(channelID, ts, name string) error {
+	if err := h.botClient.RemoveReaction(name, slack.NewRefToMessage(channelID, ts)); err != nil {
+		return fmt.Errorf("failed to remove reaction %s: %w", name, err)
+	}
+	return nil
+}
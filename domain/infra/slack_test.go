@@ -0,0 +1,116 @@
+package infra
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func searchMatch(channelID, ts, permalink string) slack.SearchMessage {
+	return slack.SearchMessage{
+		Channel:   slack.CtxChannel{ID: channelID},
+		Timestamp: ts,
+		Permalink: permalink,
+	}
+}
+
+func TestThreadRefsFromMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches []slack.SearchMessage
+		// 履歴から引ける親（channel:ts → 親のts）
+		parents     map[string]string
+		want        []threadRef
+		wantLookups int
+	}{
+		{
+			name: "several matches under the same parent",
+			matches: []slack.SearchMessage{
+				searchMatch("C1", "100.0002", "https://example.slack.com/archives/C1/p1000002?thread_ts=100.0001"),
+				searchMatch("C1", "100.0003", "https://example.slack.com/archives/C1/p1000003?thread_ts=100.0001"),
+				searchMatch("C1", "100.0001", "https://example.slack.com/archives/C1/p1000001"),
+			},
+			want:        []threadRef{{channelID: "C1", parentTS: "100.0001"}},
+			wantLookups: 0,
+		},
+		{
+			name: "permalink with thread_ts",
+			matches: []slack.SearchMessage{
+				searchMatch("C1", "200.0002", "https://example.slack.com/archives/C1/p2000002?thread_ts=200.0001&cid=C1"),
+			},
+			want:        []threadRef{{channelID: "C1", parentTS: "200.0001"}},
+			wantLookups: 0,
+		},
+		{
+			name: "same ts in different channels",
+			matches: []slack.SearchMessage{
+				searchMatch("C1", "300.0001", ""),
+				searchMatch("C2", "300.0001", ""),
+			},
+			parents: map[string]string{
+				"C1:300.0001": "300.0001",
+				"C2:300.0001": "300.0001",
+			},
+			want: []threadRef{
+				{channelID: "C1", parentTS: "300.0001"},
+				{channelID: "C2", parentTS: "300.0001"},
+			},
+			wantLookups: 2,
+		},
+		{
+			name: "reply whose parent is the other match",
+			matches: []slack.SearchMessage{
+				searchMatch("C1", "400.0002", "https://example.slack.com/archives/C1/p4000002?thread_ts=400.0001"),
+				searchMatch("C1", "400.0001", ""),
+			},
+			want:        []threadRef{{channelID: "C1", parentTS: "400.0001"}},
+			wantLookups: 0,
+		},
+		{
+			name: "reply and parent without permalinks",
+			matches: []slack.SearchMessage{
+				searchMatch("C1", "500.0001", ""),
+				searchMatch("C1", "500.0002", ""),
+				searchMatch("C1", "500.0002", ""),
+			},
+			parents: map[string]string{
+				"C1:500.0001": "500.0001",
+				"C1:500.0002": "500.0001",
+			},
+			want:        []threadRef{{channelID: "C1", parentTS: "500.0001"}},
+			wantLookups: 2,
+		},
+		{
+			name: "not allowed channel and missing message",
+			matches: []slack.SearchMessage{
+				searchMatch("C9", "600.0001", ""),
+				searchMatch("C1", "600.0002", ""),
+			},
+			want:        []threadRef{},
+			wantLookups: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups := 0
+			allowed := func(channel slack.CtxChannel) bool { return channel.ID != "C9" }
+			lookup := func(channelID, ts string) (string, bool, error) {
+				lookups++
+				parent, ok := tt.parents[channelID+":"+ts]
+				return parent, ok, nil
+			}
+
+			got, err := threadRefsFromMatches(tt.matches, allowed, lookup)
+			if err != nil {
+				t.Fatalf("threadRefsFromMatches() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("threadRefsFromMatches() = %v, want %v", got, tt.want)
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("GetConversationHistory calls = %d, want %d", lookups, tt.wantLookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/songmu/retry"
+)
+
+// 1リクエストにまとめる要約の課題数の上限
+const maxSummaryBatchSize = 5
+
+// バッチ要約のレスポンスを解釈できない（JSONが壊れている・空）ことを示すエラー
+var errInvalidBatchSummary = errors.New("invalid batch summary")
+
+// バッチ要約のレスポンスの1件分
+type batchSummary struct {
+	Index int `json:"index"`
+	model.SummaryFields
+}
+
+// GenerateSummaries は複数の課題の要約を1リクエストでまとめて生成する
+// トークン数の上限を超えた場合のみバッチを分割して再試行し、
+// レスポンスを解釈できなかった場合やレスポンスに含まれなかった課題は個別に生成する
+func (h *OpenAI) GenerateSummaries(ctx context.Context, query, lang, history string, issues []model.Result) error {
+	for start := 0; start < len(issues); start += maxSummaryBatchSize {
+		end := min(start+maxSummaryBatchSize, len(issues))
+		if err := h.generateSummaryBatch(ctx, query, lang, history, issues[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *OpenAI) generateSummaryBatch(ctx context.Context, query, lang, history string, issues []model.Result) error {
+	if len(issues) == 1 {
+		return h.GenerateSummaryForIssue(ctx, query, lang, history, &issues[0])
+	}
+
+	prompt := h.batchSummaryPrompt(query, lang, history, issues)
+	var summaries []batchSummary
+	exceeded := false
+	err := retry.Retry(3, 3*time.Second, func() error {
+		content, err := h.completeJSON(ctx, prompt)
+		if err != nil {
+			if isContextLengthExceededError(err) {
+				// 分割して再試行するのでリトライしない
+				exceeded = true
+				return nil
+			}
+			return err
+		}
+		var result struct {
+			Summaries []batchSummary `json:"summaries"`
+		}
+		if err := json.Unmarshal([]byte(content), &result); err != nil {
+			return fmt.Errorf("%w: %w", errInvalidBatchSummary, err)
+		}
+		if len(result.Summaries) == 0 {
+			return fmt.Errorf("%w: summaries is empty", errInvalidBatchSummary)
+		}
+		summaries = result.Summaries
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, errInvalidBatchSummary) {
+			return err
+		}
+		// 解釈できないレスポンスは分割しても改善しないため、下の処理で課題ごとに生成する
+		slog.WarnContext(ctx, "Failed to parse batch summary, generate individually", slog.Int("issues", len(issues)), slog.Any("err", err))
+	}
+	if exceeded {
+		slog.WarnContext(ctx, "Batch summary exceeded the context length, split the batch", slog.Int("issues", len(issues)))
+		mid := len(issues) / 2
+		if err := h.generateSummaryBatch(ctx, query, lang, history, issues[:mid]); err != nil {
+			return err
+		}
+		return h.generateSummaryBatch(ctx, query, lang, history, issues[mid:])
+	}
+
+	done := make([]bool, len(issues))
+	for _, s := range summaries {
+		if s.Index < 0 || s.Index >= len(issues) || done[s.Index] {
+			continue
+		}
+		fields := s.SummaryFields
+		issues[s.Index].SummaryFields = &fields
+		issues[s.Index].GeneratedSummary = fields.Text()
+		done[s.Index] = true
+	}
+	for i := range issues {
+		if done[i] {
+			continue
+		}
+		slog.WarnContext(ctx, "Batch summary is missing, generate individually", slog.String("issue_key", issues[i].Key))
+		if err := h.GenerateSummaryForIssue(ctx, query, lang, history, &issues[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *OpenAI) batchSummaryPrompt(query, lang, history string, issues []model.Result) string {
+	var sections []string
+	for i, issue := range issues {
+		sections = append(sections, fmt.Sprintf(`## 課題 index=%d
+### 解決状態
+%s
+### 報告者
+%s
+### 課題の内容
+%s
+### 関連するSlackのスレッド
+%s`, i, resolutionStatus(issue.Resolved), reporterOrUnknown(issue.Reporter), issue.ContentSummary, issue.SlackThread))
+	}
+
+	return fmt.Sprintf(`## 依頼内容
+以下の複数のJiraの課題それぞれについて、課題の内容と、その課題の解決方法(主にコメントとして記載されている)の結果をサマリとして自然言語で返答してください。
+あなたが作成した結果の用途は新しく課題をjiraに作成するかどうかを判断するためなので簡潔に類似かどうか判断できる材料をください。
+各課題のJira上の解決状態は課題ごとに記載しています。コメントの内容から推測せず、解決状態はこの値の通りに書いてください。
+よくわからないことはよくわからないと書いてください。
+課題同士の内容を混同しないでください。
+
+%s
+## フォーマットの指定：
+結果はsummariesフィールドに、課題ごとに以下のフィールドを持つオブジェクトの配列を持つjson形式で出力してください。
+- index: 課題の index の値（整数）
+- overview: 課題の概要を%d文字
+- resolution: 課題の解決結果を%d文字
+- assignees: この課題に関連する担当者やチーム情報（上記のメンション形式を参考に、個人とグループを区別して記載）。特定できない場合は、特定できない旨を書いてください。課題の報告者も相談相手の候補として含めてください。
+- unresolved: 課題が未解決の場合は true、解決済みの場合は false（boolean）
+
+# 過去に作成された課題
+%s
+
+%s
+%s`, mentionFormatInstruction, h.cfg.SummaryOverviewChars, h.cfg.SummaryResolutionChars, strings.Join(sections, "\n\n"), conversationSection(history), outputLanguageInstruction(h.resolveLanguage(query, lang)))
+}
+
+// 入力がモデルのトークン数の上限を超えたことによるエラーかを判定する
+func isContextLengthExceededError(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return apiErr.Code == "context_length_exceeded" || strings.Contains(apiErr.Message, "maximum context length")
+}
@@ -0,0 +1,80 @@
+package infra
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// 要約のレスポンスを項目に分解して課題に格納する
+// JSONとして解釈できない場合は本文をそのまま要約として扱う
+func setSummary(issue *model.Result, content string) {
+	fields, err := parseSummaryFields(content)
+	if err != nil {
+		slog.Warn("Failed to parse structured summary, use raw content", slog.Any("err", err))
+		issue.SummaryFields = nil
+		issue.GeneratedSummary = content
+		return
+	}
+	issue.SummaryFields = &fields
+	issue.GeneratedSummary = fields.Text()
+}
+
+func parseSummaryFields(content string) (model.SummaryFields, error) {
+	var fields model.SummaryFields
+	obj, err := extractJSONObject(content)
+	if err != nil {
+		return fields, err
+	}
+	if err := json.Unmarshal([]byte(obj), &fields); err != nil {
+		return fields, err
+	}
+	return fields, nil
+}
+
+// 生成途中のJSONの文字列フィールドの値（閉じていない場合は途中まで）
+var partialFieldPatterns = map[string]*regexp.Regexp{
+	"overview":   regexp.MustCompile(`"overview"\s*:\s*"((?:[^"\\]|\\.)*)`),
+	"resolution": regexp.MustCompile(`"resolution"\s*:\s*"((?:[^"\\]|\\.)*)`),
+	"assignees":  regexp.MustCompile(`"assignees"\s*:\s*"((?:[^"\\]|\\.)*)`),
+}
+
+var partialUnresolvedPattern = regexp.MustCompile(`"unresolved"\s*:\s*(true|false)`)
+
+// partialSummaryFields は生成途中のJSONから取り出せた範囲の項目を返す
+func partialSummaryFields(content string) model.SummaryFields {
+	value := func(key string) string {
+		m := partialFieldPatterns[key].FindStringSubmatch(content)
+		if m == nil {
+			return ""
+		}
+		return unescapeJSONString(m[1])
+	}
+	fields := model.SummaryFields{
+		Overview:   value("overview"),
+		Resolution: value("resolution"),
+		Assignees:  value("assignees"),
+	}
+	if m := partialUnresolvedPattern.FindStringSubmatch(content); m != nil {
+		fields.Unresolved = m[1] == "true"
+	}
+	return fields
+}
+
+// JSON文字列の中身をデコードする。途中で切れたエスケープは取り除く
+func unescapeJSONString(s string) string {
+	for trimmed := s; trimmed != ""; trimmed = trimmed[:len(trimmed)-1] {
+		var decoded string
+		if err := json.Unmarshal([]byte(`"`+trimmed+`"`), &decoded); err == nil {
+			return decoded
+		}
+		// 末尾の不完全なエスケープ（\ や \u30 など）のみを取り除く
+		if !strings.Contains(trimmed[max(0, len(trimmed)-6):], `\`) {
+			break
+		}
+	}
+	return ""
+}
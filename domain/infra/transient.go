@@ -0,0 +1,57 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/openai/openai-go"
+)
+
+// StatusError はJiraのエラーレスポンスを解析できなかった場合にHTTPステータスを保持する
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransientError は時間をおいて再試行すれば成功する見込みのあるエラーかを返す
+// タイムアウト・5xx・レート制限が該当し、JQLの検証エラーなど入力に起因するものは該当しない
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return transientStatus(apiErr.StatusCode)
+	}
+	var jqlErr *JQLError
+	if errors.As(err, &jqlErr) {
+		return transientStatus(jqlErr.StatusCode)
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return transientStatus(statusErr.StatusCode)
+	}
+	return false
+}
+
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,62 @@
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pyama86/jipcy/config"
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// Webhook は処理結果を外部システムへ連携するクライアント
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(cfg *config.Config) *Webhook {
+	return &Webhook{
+		url:    cfg.Handler.WebhookURL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled はWebhook URLが設定されているかを返す
+func (w *Webhook) Enabled() bool {
+	return w.url != ""
+}
+
+// Post は結果をJSONでPOSTする
+func (w *Webhook) Post(payload model.WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// PostAsync はメイン処理をブロックしないよう非同期で送信し、失敗はログのみ残す
+func (w *Webhook) PostAsync(payload model.WebhookPayload) {
+	if !w.Enabled() {
+		return
+	}
+	go func() {
+		if err := w.Post(payload); err != nil {
+			slog.Error("Failed to send result webhook", slog.Any("err", err))
+		}
+	}()
+}
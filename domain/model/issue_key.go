@@ -0,0 +1,28 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+var issueKeyPattern = regexp.MustCompile(`\b([A-Z][A-Z0-9_]+)-([0-9]+)\b`)
+
+// ExtractIssueKeys はテキストから指定したプロジェクトの課題キーを出現順に抽出する
+// UTF-8 のような課題キーに似た文字列を拾わないようプロジェクトキーで絞り込む
+func ExtractIssueKeys(text string, projectKeys []string) []string {
+	projects := make(map[string]bool)
+	for _, p := range projectKeys {
+		projects[strings.ToUpper(p)] = true
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range issueKeyPattern.FindAllStringSubmatch(text, -1) {
+		if !projects[m[1]] || seen[m[0]] {
+			continue
+		}
+		seen[m[0]] = true
+		keys = append(keys, m[0])
+	}
+	return keys
+}
@@ -1,13 +1,67 @@
 package model
 
+import "strings"
+
 type Result struct {
-	ID               string `json:"id"`
-	Summary          string `json:"summary"`
-	Description      string `json:"description"`
-	URL              string `json:"url"`
-	Similarity       float64
-	ContentSummary   string `json:"content_summary"`
-	GeneratedSummary string `json:"generated_summary"`
-	SlackThread      string `json:"slack_thread"`
-	SlackThreadURL   string `json:"slack_thread_url"`
+	ID               string  `json:"id"`
+	Key              string  `json:"key"`
+	Summary          string  `json:"summary"`
+	Description      string  `json:"description"`
+	URL              string  `json:"url"`
+	Similarity       float64 `json:"similarity"`
+	ContentSummary   string  `json:"content_summary"`
+	GeneratedSummary string  `json:"generated_summary"`
+	// 要約を項目ごとに分解したもの（構造化出力に失敗した場合は nil）
+	SummaryFields  *SummaryFields `json:"summary_fields,omitempty"`
+	SlackThread    string         `json:"slack_thread"`
+	SlackThreadURL string         `json:"slack_thread_url"`
+	Reporter       string         `json:"reporter"`
+	WatchCount     int            `json:"watch_count"`
+	Status         string         `json:"status"`
+	Assignee       string         `json:"assignee"`
+	Priority       string         `json:"priority"`
+	// Jiraの resolution とステータスカテゴリから判定した解決状態
+	Resolved bool `json:"resolved"`
+	// 問い合わせ内で課題キーが直接指定された課題
+	Referenced bool `json:"referenced"`
+	// 類似度がしきい値未満だが参考として表示する課題
+	LowSimilarity bool `json:"low_similarity"`
+	// 同じSlackスレッドに紐づく他の課題
+	RelatedIssues []RelatedIssue `json:"related_issues,omitempty"`
+	// 課題本文・コメントから抽出した参照URL・関連課題
+	References []Reference `json:"references,omitempty"`
+}
+
+// 参考リンク
+type Reference struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// 要約の項目
+type SummaryFields struct {
+	Overview   string `json:"overview"`
+	Resolution string `json:"resolution"`
+	Assignees  string `json:"assignees"`
+	// LLMが課題を未解決と判断した場合に true
+	Unresolved bool `json:"unresolved"`
+}
+
+// Text は項目を空行区切りで連結したテキストを返す
+func (f SummaryFields) Text() string {
+	var parts []string
+	for _, p := range []string{f.Overview, f.Resolution, f.Assignees} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+type RelatedIssue struct {
+	ID         string  `json:"id"`
+	Key        string  `json:"key"`
+	Summary    string  `json:"summary"`
+	URL        string  `json:"url"`
+	Similarity float64 `json:"similarity"`
 }
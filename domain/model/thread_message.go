@@ -3,6 +3,8 @@ package model
 type ThreadMessage struct {
 	ChannelID string
 	Timestamp string
-	User      string
-	Text      string
+	// スレッドの親メッセージのタイムスタンプ
+	ThreadTimestamp string
+	User            string
+	Text            string
 }
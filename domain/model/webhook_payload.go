@@ -0,0 +1,8 @@
+package model
+
+type WebhookPayload struct {
+	UserID    string   `json:"user_id"`
+	ChannelID string   `json:"channel_id"`
+	Query     string   `json:"query"`
+	Results   []Result `json:"results"`
+}
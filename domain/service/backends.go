@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pyama86/jipcy/domain/infra"
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// 類似課題の選定で使う外部サービスの機能
+// テストで差し替えられるよう、必要なメソッドのみをインターフェースにする
+
+// similarityBackend は類似度計算に使うLLMの機能（*infra.OpenAI が実装する）
+type similarityBackend interface {
+	CalculateSimilarity(ctx context.Context, issueKey, query, contentSummary, slackThreadMessages string) (float64, error)
+	CondenseIssue(ctx context.Context, contentSummary string) (string, error)
+	CreateEmbedding(ctx context.Context, text string) ([]float64, error)
+}
+
+// threadSearcher は課題に関するSlackスレッドの検索機能（*infra.Slack が実装する）
+type threadSearcher interface {
+	SearchThreads(ctx context.Context, keyword, channelID string) ([]model.ThreadMessage, error)
+	SearchThreadsByKeywords(ctx context.Context, keywords []string, matchAll bool, channelID string, maxThreads int) ([]model.ThreadMessage, error)
+	FormattedSearchThreads(ctx context.Context, threads []model.ThreadMessage) (string, error)
+	GetThreadPermalink(ctx context.Context, workspaceURL string, msg model.ThreadMessage) string
+}
+
+// sprintFetcher は課題のスプリント情報の取得機能（*infra.Jira が実装する）
+type sprintFetcher interface {
+	FetchSprintInfo(ctx context.Context, key string) ([]infra.Sprint, error)
+}
+
+var (
+	_ similarityBackend = (*infra.OpenAI)(nil)
+	_ threadSearcher    = (*infra.Slack)(nil)
+	_ sprintFetcher     = (*infra.Jira)(nil)
+)
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+	"github.com/pyama86/jipcy/config"
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// ユーザーごとに保持する直近の問い合わせの件数
+const maxConversationTurns = 3
+
+// ConversationMemory はユーザーごとの直近の問い合わせと選定課題を短時間保持する
+// CONVERSATION_MEMORY が無効の場合は何も保持しない
+type ConversationMemory struct {
+	mu    sync.Mutex
+	cache *ttlcache.Cache[string, []conversationTurn]
+}
+
+// 1回分の問い合わせと選定された課題
+type conversationTurn struct {
+	query  string
+	issues []string
+}
+
+func NewConversationMemory(cfg *config.Config) *ConversationMemory {
+	if !cfg.Handler.ConversationMemory {
+		return &ConversationMemory{}
+	}
+	m := &ConversationMemory{
+		cache: ttlcache.New(ttlcache.WithTTL[string, []conversationTurn](cfg.Handler.ConversationMemoryTTL)),
+	}
+	go m.cache.Start()
+	return m
+}
+
+// Add は問い合わせと選定された課題を記録する。TTLは最後の問い合わせから数える
+func (m *ConversationMemory) Add(userID, query string, results []model.Result) {
+	if m.cache == nil || userID == "" {
+		return
+	}
+	turn := conversationTurn{query: query}
+	for _, r := range results {
+		turn.issues = append(turn.issues, fmt.Sprintf("%s %s", r.Key, r.Summary))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var turns []conversationTurn
+	if item := m.cache.Get(userID); item != nil {
+		turns = item.Value()
+	}
+	turns = append(turns, turn)
+	if len(turns) > maxConversationTurns {
+		turns = turns[len(turns)-maxConversationTurns:]
+	}
+	m.cache.Set(userID, turns, ttlcache.DefaultTTL)
+}
+
+// Context はプロンプトに渡す直前の文脈を古い順のテキストで返す。記録が無い場合は空を返す
+func (m *ConversationMemory) Context(userID string) string {
+	if m.cache == nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 参照ではTTLを延長しない
+	item := m.cache.Get(userID, ttlcache.WithDisableTouchOnHit[string, []conversationTurn]())
+	if item == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for i, turn := range item.Value() {
+		fmt.Fprintf(&b, "%d. 問い合わせ: %s\n", i+1, turn.query)
+		for _, issue := range turn.issues {
+			fmt.Fprintf(&b, "   - 選定された課題: %s\n", issue)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+)
+
+// 課題ごとの処理結果の扱い
+//
+// 類似課題の選定では次の方針で失敗を扱う
+//   - 課題単位の失敗（Slack検索・類似度計算のエラーやリトライ上限）は、その課題の結果を空にして他の課題の処理を継続する
+//   - SELECT_TIMEOUT による打ち切りは評価できなかった課題として数え、評価済みの課題から結果を返す
+//   - 問い合わせ全体の context がキャンセルされた場合のみ致命的なエラーとして選定全体を失敗させる
+type issueOutcome int
+
+const (
+	// 処理に成功した（類似度がしきい値未満で除外した場合を含む）
+	issueSucceeded issueOutcome = iota
+	// 課題単位で失敗した。結果を空にして継続する
+	issueFailed
+	// SELECT_TIMEOUT により打ち切った。評価できなかった課題として数える
+	issueTimedOut
+	// 問い合わせ全体がキャンセルされた。選定全体を失敗させる
+	issueAborted
+)
+
+// classifyIssueError は課題の処理で発生したエラーを上記の方針に従って分類する
+// ctx は問い合わせ全体、selectCtx は SELECT_TIMEOUT の期限を付けた context
+func classifyIssueError(ctx, selectCtx context.Context, err error) issueOutcome {
+	switch {
+	case ctx.Err() != nil:
+		return issueAborted
+	case selectCtx.Err() != nil:
+		return issueTimedOut
+	case err != nil:
+		return issueFailed
+	default:
+		return issueSucceeded
+	}
+}
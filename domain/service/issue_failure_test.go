@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassifyIssueError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	active := context.Background()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		selectCtx context.Context
+		err       error
+		want      issueOutcome
+	}{
+		{
+			name:      "parent context cancelled",
+			ctx:       cancelled,
+			selectCtx: cancelled,
+			err:       context.Canceled,
+			want:      issueAborted,
+		},
+		{
+			name:      "select deadline exceeded",
+			ctx:       active,
+			selectCtx: expired,
+			err:       context.DeadlineExceeded,
+			want:      issueTimedOut,
+		},
+		{
+			name:      "issue error",
+			ctx:       active,
+			selectCtx: active,
+			err:       errors.New("similarity failed"),
+			want:      issueFailed,
+		},
+		{
+			name:      "no error",
+			ctx:       active,
+			selectCtx: active,
+			err:       nil,
+			want:      issueSucceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyIssueError(tt.ctx, tt.selectCtx, tt.err); got != tt.want {
+				t.Errorf("classifyIssueError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
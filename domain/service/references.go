@@ -0,0 +1,42 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/pyama86/jipcy/urlutil"
+)
+
+// 1件の課題に付ける参考リンクの最大数
+const maxReferences = 5
+
+// 本文・コメント中のURL（wiki記法の区切りや括弧の手前までを対象にする）
+var referenceURLPattern = regexp.MustCompile(`https?://[^\s<>|"'\[\]()（）]+`)
+
+// 課題本文・コメントから参照URLと関連課題キーを抽出する
+// 課題自身へのリンクは除き、出現順に maxReferences 件まで返す
+func (s *SelectTopIssueService) extractReferences(issueKey, jiraURL, content string) []model.Reference {
+	var refs []model.Reference
+	seen := map[string]bool{urlutil.Normalize(jiraURL): true}
+	add := func(label, url string) {
+		normalized := urlutil.Normalize(url)
+		if seen[normalized] || len(refs) >= maxReferences {
+			return
+		}
+		seen[normalized] = true
+		refs = append(refs, model.Reference{Label: label, URL: url})
+	}
+
+	for _, key := range model.ExtractIssueKeys(content, s.projectKeys) {
+		if key != issueKey {
+			add(key, fmt.Sprintf("%s/browse/%s", s.jiraEndpoint, key))
+		}
+	}
+	for _, url := range referenceURLPattern.FindAllString(content, -1) {
+		url = strings.TrimRight(url, ".,;:!?。、")
+		add(url, url)
+	}
+	return refs
+}
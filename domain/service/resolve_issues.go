@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pyama86/jipcy/domain/infra"
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// ResolveIssues はユーザーが明示的に指定した課題を類似度で絞り込まずに結果へ変換する
+func (s *SelectTopIssueService) ResolveIssues(ctx context.Context, issues []infra.Issue, channelID string) []model.Result {
+	jiraendpoint := s.jiraEndpoint
+	workspaceURL := s.workspaceURL
+
+	results := make([]model.Result, 0, len(issues))
+	for _, issue := range issues {
+		contentSummary := formatIssue(issue)
+		jiraURL := fmt.Sprintf("%s/browse/%s", jiraendpoint, issue.Key)
+
+		threads, slackThreadMessages := s.searchSlackThreads(ctx, jiraURL, issue.Fields.Summary, channelID)
+		result := s.newResult(ctx, issue, jiraURL, contentSummary, threads, slackThreadMessages, workspaceURL)
+		result.Referenced = true
+		results = append(results, result)
+	}
+	results = dedupeResults(ctx, results)
+	s.attachSprints(ctx, results)
+	return results
+}
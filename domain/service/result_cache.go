@@ -0,0 +1,53 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+	"github.com/pyama86/jipcy/config"
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// ResultCache は同一の問い合わせに対する最終結果を短時間キャッシュする
+// RESULT_CACHE_TTL が未設定の場合は何もキャッシュしない
+type ResultCache struct {
+	cache *ttlcache.Cache[string, []model.Result]
+}
+
+func NewResultCache(cfg *config.Config) *ResultCache {
+	ttl := cfg.Selection.ResultCacheTTL
+	if ttl == 0 {
+		return &ResultCache{}
+	}
+	c := &ResultCache{
+		cache: ttlcache.New(ttlcache.WithTTL[string, []model.Result](ttl)),
+	}
+	go c.cache.Start()
+	return c
+}
+
+// 問い合わせ文の空白・大文字小文字の揺れを吸収したハッシュをキーにする
+func resultCacheKey(query string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *ResultCache) Get(query string) ([]model.Result, bool) {
+	if c.cache == nil {
+		return nil, false
+	}
+	if item := c.cache.Get(resultCacheKey(query)); item != nil {
+		return item.Value(), true
+	}
+	return nil, false
+}
+
+func (c *ResultCache) Set(query string, results []model.Result) {
+	if c.cache == nil {
+		return
+	}
+	c.cache.Set(resultCacheKey(query), results, ttlcache.DefaultTTL)
+}
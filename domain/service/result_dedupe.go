@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/pyama86/jipcy/urlutil"
+)
+
+// 結果のURLを正規化し、同じ課題を指す結果を除外する
+// 先に並んでいる結果を残すため、類似度でソートした後に呼び出す
+func dedupeResults(ctx context.Context, results []model.Result) []model.Result {
+	deduped := make([]model.Result, 0, len(results))
+	seen := make(map[string]bool, len(results))
+	for _, result := range results {
+		result.URL = urlutil.Normalize(result.URL)
+		result.SlackThreadURL = urlutil.Normalize(result.SlackThreadURL)
+		if result.URL != "" && seen[result.URL] {
+			slog.DebugContext(ctx, "Skip duplicate result", slog.String("issue_key", result.Key), slog.String("url", result.URL))
+			continue
+		}
+		seen[result.URL] = true
+		deduped = append(deduped, result)
+	}
+	return deduped
+}
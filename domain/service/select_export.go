@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pyama86/jipcy/config"
+)
+
+// 書き込み待ちのレコード数の上限。超えた分は破棄する
+const selectExportBuffer = 256
+
+// SelectExporter はissueごとの処理結果をNDJSONでファイルに追記する
+// SELECT_EXPORT_PATH が未設定の場合は何も出力しない
+type SelectExporter struct {
+	records chan selectRecord
+}
+
+// 1issue分の処理結果
+type selectRecord struct {
+	Time           time.Time `json:"time"`
+	TraceID        string    `json:"trace_id,omitempty"`
+	UserID         string    `json:"user_id"`
+	Query          string    `json:"query"`
+	IssueKey       string    `json:"issue_key"`
+	Attempts       int       `json:"attempts"`
+	Summary        string    `json:"summary"`
+	Similarity     float64   `json:"similarity"`
+	Excluded       bool      `json:"excluded"`
+	LowSimilarity  bool      `json:"low_similarity"`
+	HasThread      bool      `json:"has_thread"`
+	ThreadMessages int       `json:"thread_messages"`
+	DurationMs     int64     `json:"duration_ms"`
+	SlackSearchMs  int64     `json:"slack_search_ms"`
+	SimilarityMs   int64     `json:"similarity_ms"`
+	Error          string    `json:"error,omitempty"`
+}
+
+func NewSelectExporter(cfg *config.Config) *SelectExporter {
+	if cfg.Selection.ExportPath == "" {
+		return &SelectExporter{}
+	}
+	e := &SelectExporter{records: make(chan selectRecord, selectExportBuffer)}
+	go e.run(cfg.Selection.ExportPath)
+	return e
+}
+
+// 書き込みを1つのgoroutineに集約し、行が混ざらないようにする
+func (e *SelectExporter) run(path string) {
+	for record := range e.records {
+		if err := appendNDJSON(path, record); err != nil {
+			slog.Error("Failed to export select result", slog.String("path", path), slog.Any("err", err))
+		}
+	}
+}
+
+func appendNDJSON(path string, v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// export はメイン処理をブロックしないようにレコードを書き込み待ちに積む
+func (e *SelectExporter) export(record selectRecord) {
+	if e == nil || e.records == nil {
+		return
+	}
+	select {
+	case e.records <- record:
+	default:
+		slog.Warn("Select export buffer is full, record dropped", slog.String("issue_key", record.IssueKey))
+	}
+}
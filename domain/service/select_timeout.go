@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SelectTimeout は SELECT_TIMEOUT により選定処理を打ち切ったときの件数
+type SelectTimeout struct {
+	Timeout time.Duration
+	// 類似度を評価できた課題の数
+	Processed int
+	// 評価対象の課題の数
+	Total int
+}
+
+// 選定処理全体の期限を設定する
+func (s *SelectTopIssueService) withSelectTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.selectTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.selectTimeout)
+}
+
+// 打ち切った課題があれば記録する
+func (s *SelectTopIssueService) recordTimeout(ctx context.Context, total, timedOut int) {
+	if timedOut == 0 {
+		return
+	}
+	s.timedOut = &SelectTimeout{
+		Timeout:   s.selectTimeout,
+		Processed: total - timedOut,
+		Total:     total,
+	}
+	slog.WarnContext(ctx, "Select timed out, returning best effort results",
+		slog.Duration("timeout", s.selectTimeout),
+		slog.Int("processed", total-timedOut),
+		slog.Int("total", total))
+}
+
+// TimedOut は直前の SelectTopIssues を期限切れで打ち切った場合にその件数を返す
+func (s *SelectTopIssueService) TimedOut() *SelectTimeout {
+	return s.timedOut
+}
@@ -2,27 +2,107 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pyama86/jipcy/config"
 	"github.com/pyama86/jipcy/domain/infra"
 	"github.com/pyama86/jipcy/domain/model"
+	"github.com/pyama86/jipcy/logging"
 	"github.com/slack-go/slack"
 	"github.com/songmu/retry"
 	"golang.org/x/sync/errgroup"
-	"golang.org/x/sync/semaphore"
 )
 
+// 結果に含める類似度の下限
+const SimilarityThreshold = 0.3
+
+// 結果として返す課題の最大数
+const MaxTopIssues = 5
+
+// RESULT_PAGE_SIZE でページ送りする場合に結果として保持する課題の最大数
+const MaxPagedIssues = 50
+
+// 結果として返す課題の最大数を返す
+// ページ送りする場合は続きのページに表示できるよう上位の結果を多めに保持する
+func ResultLimit(pageSize int) int {
+	if pageSize > 0 {
+		return MaxPagedIssues
+	}
+	return MaxTopIssues
+}
+
+// しきい値以上の課題が無い場合に参考として返す低類似度の課題の最大数
+const maxLowSimilarityFallback = 2
+
 type SelectTopIssueService struct {
-	openAI      *infra.OpenAI
-	slack       *infra.Slack
-	jira        *infra.Jira
+	openAI      similarityBackend
+	slack       threadSearcher
+	jira        sprintFetcher
 	slackClient *slack.Client
+	exporter    *SelectExporter
+	// 結果のURLの組み立てに使うエンドポイント
+	jiraEndpoint string
+	// 参考リンクとして抽出する課題キーのプロジェクト
+	projectKeys  []string
+	workspaceURL string
+	// 各ステップのタイムアウト
+	slackSearchTimeout time.Duration
+	slackFormatTimeout time.Duration
+	similarityTimeout  time.Duration
+	// 選定処理全体のタイムアウト（0の場合は無制限）
+	selectTimeout time.Duration
+	// 課題ごとの処理を再試行するまでの間隔
+	issueRetryDelay time.Duration
+	// 選定処理を打ち切った場合の件数（打ち切っていない場合は nil）
+	timedOut *SelectTimeout
+	// false の場合は課題ごとの処理経過を通知しない
+	verboseSteps bool
+	// 課題のサマリによるSlack検索のモード（"and" / "or"、空の場合は無効）
+	searchBySummary string
+	// URL検索とサマリ検索を合わせたスレッド数の上限
+	searchMaxThreads int
+	// true の場合はEmbeddingで上位 stage1TopN 件に絞ってから類似度を計算する
+	twoStageRanking bool
+	stage1TopN      int
+	// true の場合はしきい値以上の課題が無いときに低類似度の上位候補を返す
+	fallbackLowSimilarity bool
+	// 類似度を計算する課題の最大数
+	maxProcessIssues int
+	// 課題ごとの処理の並列度
+	concurrency int
+	// true の場合は類似度計算の前に課題を要点に圧縮する
+	condenseBeforeSimilarity bool
+	// true の場合は長いスレッドから重要な発言を抽出してから使う
+	threadSummarize bool
+	// Slackスレッドの返信数を類似度に加味する重み（0の場合は加味しない）
+	threadEngagementWeight float64
+	// true の場合は同じスレッドに紐づく結果をまとめる
+	groupByThread bool
+	// 結果として返す課題の最大数
+	maxResults int
+	// Slackに通知する文言（問い合わせたユーザーの言語に合わせる）
+	messages Messages
+	// true の場合は類似度の分布をSlackにも表示する
+	showDistribution bool
+}
+
+// Messages はメッセージのキーと引数から表示言語に合わせた文言を返す
+type Messages func(key string, args ...any) string
+
+// 通知の文言を返す。文言が設定されていない場合はキーをそのまま返す
+func (s *SelectTopIssueService) message(key string, args ...any) string {
+	if s.messages == nil {
+		return key
+	}
+	return s.messages(key, args...)
 }
 
 // 通知メッセージの構造体
@@ -30,53 +110,214 @@ type notificationMessage struct {
 	message         string
 	channelID       string
 	threadTimestamp string
+	// 他の通知と送信順序が前後してよい場合は true
+	unordered bool
 }
 
-func NewSelectTopIssueService(openAI *infra.OpenAI, slackInfra *infra.Slack, jira *infra.Jira, slackClient *slack.Client) *SelectTopIssueService {
+func NewSelectTopIssueService(cfg *config.Config, openAI *infra.OpenAI, slackInfra *infra.Slack, jira *infra.Jira, slackClient *slack.Client, exporter *SelectExporter) *SelectTopIssueService {
 	return &SelectTopIssueService{
-		openAI:      openAI,
-		slack:       slackInfra,
-		jira:        jira,
-		slackClient: slackClient,
+		openAI:                   openAI,
+		slack:                    slackInfra,
+		jira:                     jira,
+		slackClient:              slackClient,
+		exporter:                 exporter,
+		jiraEndpoint:             strings.TrimSuffix(cfg.Jira.Endpoint, "/"),
+		projectKeys:              cfg.Jira.ProjectKeys(),
+		workspaceURL:             cfg.Slack.WorkspaceURL,
+		slackSearchTimeout:       cfg.Selection.SlackSearchTimeout,
+		slackFormatTimeout:       cfg.Selection.SlackFormatTimeout,
+		similarityTimeout:        cfg.Selection.SimilarityTimeout,
+		selectTimeout:            cfg.Selection.SelectTimeout,
+		issueRetryDelay:          3 * time.Second,
+		verboseSteps:             cfg.Handler.VerboseSteps,
+		searchBySummary:          cfg.Slack.SearchBySummary,
+		searchMaxThreads:         cfg.Slack.SearchMaxThreads,
+		twoStageRanking:          cfg.Selection.TwoStageRanking,
+		stage1TopN:               cfg.Selection.Stage1TopN,
+		fallbackLowSimilarity:    cfg.Selection.FallbackLowSimilarity,
+		maxProcessIssues:         cfg.Selection.MaxProcessIssues,
+		concurrency:              cfg.Selection.Concurrency,
+		condenseBeforeSimilarity: cfg.Selection.CondenseBeforeSimilarity,
+		threadSummarize:          cfg.Selection.ThreadSummarize,
+		threadEngagementWeight:   cfg.Selection.ThreadEngagementWeight,
+		groupByThread:            cfg.Slack.GroupByThread,
+		maxResults:               ResultLimit(cfg.Handler.ResultPageSize),
+		showDistribution:         cfg.Selection.ShowDistribution,
 	}
 }
 
-// 通知を順次送信するworker
+// タイムアウト付きでステップを実行する
+func withTimeout[T any](ctx context.Context, timeout time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// 通知の再送回数と再送間隔の初期値
+const (
+	maxNotificationRetries = 3
+	notificationRetryDelay = 1 * time.Second
+)
+
+// 通知の並列送信数と、rate limitを考慮した全体の送信レート
+const (
+	notificationWorkers  = 3
+	notificationBurst    = 3
+	notificationInterval = 500 * time.Millisecond
+)
+
+// SetVerboseSteps は課題ごとの処理経過を通知するかを上書きする
+func (s *SelectTopIssueService) SetVerboseSteps(v bool) {
+	s.verboseSteps = v
+}
+
+// SetMessages は通知に使う文言を設定する
+func (s *SelectTopIssueService) SetMessages(m Messages) {
+	s.messages = m
+}
+
+// 通知を送信するworker
+// 順序を問わない通知（unordered）は複数のgoroutineで並列に送り、それ以外は先行する通知の完了を待って直列に送る
+// 全体の送信レートはトークンバケットで制限し、最終的に送れなかった通知は終了時にまとめて報告する
 func (s *SelectTopIssueService) notificationWorker(ctx context.Context, notifyCh <-chan notificationMessage, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	// rate limitを考慮した間隔で通知を送信
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+	sender := &notificationSender{
+		post:   s.postNotification,
+		bucket: newTokenBucket(notificationBurst, notificationInterval),
+	}
+
+	parallelCh := make(chan notificationMessage)
+	var workers, inflight sync.WaitGroup
+	for range notificationWorkers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for msg := range parallelCh {
+				sender.send(ctx, msg)
+				inflight.Done()
+			}
+		}()
+	}
+	stop := func() {
+		close(parallelCh)
+		workers.Wait()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Info("Notification worker stopped by context cancellation")
+			stop()
+			slog.InfoContext(ctx, "Notification worker stopped by context cancellation")
 			return
 		case msg, ok := <-notifyCh:
 			if !ok {
-				slog.Info("Notification worker stopped: channel closed")
+				stop()
+				s.reportFailedNotifications(ctx, sender.failedMessages())
+				slog.InfoContext(ctx, "Notification worker stopped: channel closed")
 				return
 			}
-			// rate limitを考慮して送信
-			<-ticker.C
-			_, _, err := s.slackClient.PostMessage(
-				msg.channelID,
-				slack.MsgOptionText(msg.message, false),
-				slack.MsgOptionTS(msg.threadTimestamp),
-				slack.MsgOptionLinkNames(false),
-			)
-			if err != nil {
-				// 通知失敗時はログに記録するが、処理は継続
-				slog.Error("Failed to send notification (processing will continue)",
-					slog.String("message", msg.message),
-					slog.Any("error", err))
+			if !s.verboseSteps {
+				continue
+			}
+			if msg.unordered {
+				inflight.Add(1)
+				parallelCh <- msg
+				continue
 			}
+			// 順序が重要な通知は送信中の通知を追い越さないようにする
+			inflight.Wait()
+			sender.send(ctx, msg)
 		}
 	}
 }
 
+// トークンバケットでレートを守りながら通知を送信し、失敗した場合は指数バックオフで再送する
+type notificationSender struct {
+	post   func(notificationMessage) error
+	bucket *tokenBucket
+
+	mu     sync.Mutex
+	failed []notificationMessage
+}
+
+func (n *notificationSender) send(ctx context.Context, msg notificationMessage) {
+	for attempts := 1; ; attempts++ {
+		if err := n.bucket.wait(ctx); err != nil {
+			return
+		}
+		err := n.post(msg)
+		if err == nil {
+			return
+		}
+		if attempts > maxNotificationRetries {
+			slog.ErrorContext(ctx, "Failed to send notification, giving up",
+				slog.String("message", msg.message),
+				slog.Int("attempts", attempts),
+				slog.Any("error", err))
+			n.mu.Lock()
+			n.failed = append(n.failed, msg)
+			n.mu.Unlock()
+			return
+		}
+		delay := notificationRetryDelay << (attempts - 1)
+		var rateLimitErr *slack.RateLimitedError
+		if errors.As(err, &rateLimitErr) {
+			// rate limitは全workerで共有して送信を止める
+			n.bucket.pause(rateLimitErr.RetryAfter)
+			if rateLimitErr.RetryAfter > delay {
+				delay = rateLimitErr.RetryAfter
+			}
+		}
+		// 通知失敗時は再送し、課題の処理は継続
+		slog.WarnContext(ctx, "Failed to send notification, will retry (processing will continue)",
+			slog.String("message", msg.message),
+			slog.Int("attempts", attempts),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (n *notificationSender) failedMessages() []notificationMessage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.failed
+}
+
+func (s *SelectTopIssueService) postNotification(msg notificationMessage) error {
+	_, _, err := s.slackClient.PostMessage(
+		msg.channelID,
+		slack.MsgOptionText(msg.message, false),
+		slack.MsgOptionTS(msg.threadTimestamp),
+		slack.MsgOptionLinkNames(false),
+	)
+	return err
+}
+
+// 再送しても送れなかった通知をまとめて1通で報告する
+func (s *SelectTopIssueService) reportFailedNotifications(ctx context.Context, failed []notificationMessage) {
+	if len(failed) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(failed))
+	for _, msg := range failed {
+		lines = append(lines, "• "+msg.message)
+	}
+	report := notificationMessage{
+		message:         s.message("select_failed_notifications", len(failed), strings.Join(lines, "\n")),
+		channelID:       failed[0].channelID,
+		threadTimestamp: failed[0].threadTimestamp,
+	}
+	if err := s.postNotification(report); err != nil {
+		slog.ErrorContext(ctx, "Failed to report failed notifications", slog.Int("count", len(failed)), slog.Any("error", err))
+	}
+}
+
 func formatIssue(issue infra.Issue) string {
 	// 新しいADF対応メソッドを使用してコメントを取得
 	issueComments := issue.GetComments()
@@ -88,151 +329,385 @@ func formatIssue(issue infra.Issue) string {
 		formattedComments = append(formattedComments, fmt.Sprintf("### %s", safeComment))
 	}
 
-	return fmt.Sprintf(`## 概要
+	formatted := fmt.Sprintf(`## 概要
+%s
+## 報告者
+%s (ウォッチャー数: %d)
+## 解決状態
 %s
 ## 詳細
 %s
 ## コメントの履歴
-%s`, issue.Fields.Summary, issue.GetDescription(), strings.Join(formattedComments, "\n\n"))
+%s`, issue.Fields.Summary, issue.GetReporterName(), issue.Fields.Watches.WatchCount, resolutionText(issue), issue.GetDescription(), strings.Join(formattedComments, "\n\n"))
+
+	// 子課題だけでは文脈が不足するため、親課題がある場合はそのサマリを添える
+	if key, summary, ok := issue.GetParent(); ok {
+		formatted += fmt.Sprintf("\n## 親課題\n%s: %s", key, summary)
+	}
+
+	// 添付ファイルは内容を取得せずファイル名とサイズのみ表示
+	if len(issue.Fields.Attachment) > 0 {
+		var attachments []string
+		for _, a := range issue.Fields.Attachment {
+			attachments = append(attachments, fmt.Sprintf("- %s (%s)", a.Filename, formatFileSize(a.Size)))
+		}
+		formatted += fmt.Sprintf("\n## 添付ファイル\n%s", strings.Join(attachments, "\n"))
+	}
+
+	return formatted
+}
+
+// スプリント情報の見出しと一覧を返す（取得できなかった場合は空）
+// 選定後の結果にのみ付与するため formatIssue とは別に組み立てる
+func sprintSection(sprints []infra.Sprint) string {
+	if len(sprints) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(sprints))
+	for _, sprint := range sprints {
+		lines = append(lines, fmt.Sprintf("- %s (%s)", sprint.Name, sprint.State))
+	}
+	return fmt.Sprintf("\n## スプリント\n%s", strings.Join(lines, "\n"))
+}
+
+// 課題の解決状態を表示用の文字列にする
+func resolutionText(issue infra.Issue) string {
+	if !issue.IsResolved() {
+		return "未解決"
+	}
+	if name := issue.GetResolutionName(); name != "" {
+		return fmt.Sprintf("解決済み (%s)", name)
+	}
+	return "解決済み"
+}
+
+// 課題URLが貼られたSlackスレッドを検索し、スレッドと整形済みテキストを返す
+// SLACK_SEARCH_BY_SUMMARY が有効な場合は課題のサマリのキーワードでも検索してマージする
+// Slack検索は類似度計算の補助なので、失敗してもスレッド情報なしとして警告ログのみ出して続行する
+func (s *SelectTopIssueService) searchSlackThreads(ctx context.Context, jiraURL, summary, channelID string) ([]model.ThreadMessage, string) {
+	threads, err := withTimeout(ctx, s.slackSearchTimeout, func(ctx context.Context) ([]model.ThreadMessage, error) {
+		return s.slack.SearchThreads(ctx, jiraURL, channelID)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to search threads, continue without threads", slog.String("url", jiraURL), slog.Any("err", err))
+		threads = nil
+	}
+
+	if s.searchBySummary != "" {
+		keywords := summaryKeywords(summary)
+		keywordThreads, err := withTimeout(ctx, s.slackSearchTimeout, func(ctx context.Context) ([]model.ThreadMessage, error) {
+			return s.slack.SearchThreadsByKeywords(ctx, keywords, s.searchBySummary == "and", channelID, s.searchMaxThreads)
+		})
+		if err != nil {
+			// サマリ検索は補助的なものなので、失敗してもURL検索の結果で続行する
+			slog.WarnContext(ctx, "Failed to search threads by summary", slog.Any("keywords", keywords), slog.Any("err", err))
+		} else {
+			threads = mergeThreads(threads, keywordThreads, keywords, s.searchMaxThreads)
+		}
+	}
+
+	formatTarget := threads
+	if s.threadSummarize {
+		formatTarget = summarizeThread(threads)
+	}
+	slackThreadMessages, err := withTimeout(ctx, s.slackFormatTimeout, func(ctx context.Context) (string, error) {
+		return s.slack.FormattedSearchThreads(ctx, formatTarget)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to format threads, continue without thread messages", slog.String("url", jiraURL), slog.Any("err", err))
+		return threads, ""
+	}
+	return threads, slackThreadMessages
+}
+
+// 課題とSlackスレッドから結果を組み立てる
+func (s *SelectTopIssueService) newResult(ctx context.Context, issue infra.Issue, jiraURL, contentSummary string, threads []model.ThreadMessage, slackThreadMessages, workspaceURL string) model.Result {
+	result := model.Result{
+		ID:             issue.ID,
+		Key:            issue.Key,
+		Summary:        issue.Fields.Summary,
+		Description:    issue.GetDescription(),
+		URL:            jiraURL,
+		ContentSummary: contentSummary,
+		SlackThread:    slackThreadMessages,
+		Reporter:       issue.GetReporterName(),
+		WatchCount:     issue.Fields.Watches.WatchCount,
+		Status:         issue.GetStatusName(),
+		Assignee:       issue.GetAssigneeName(),
+		Priority:       issue.GetPriorityName(),
+		Resolved:       issue.IsResolved(),
+		References:     s.extractReferences(issue.Key, jiraURL, contentSummary),
+	}
+
+	if len(threads) > 0 {
+		result.SlackThreadURL = s.slack.GetThreadPermalink(ctx, workspaceURL, threads[0])
+	}
+	return result
+}
+
+// ファイルサイズを読みやすい単位に変換する
+func formatFileSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/1024/1024)
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
 }
 
 // Jiraの問い合わせから最も類似している3件を選択する関数（並列化版）
-func (s *SelectTopIssueService) SelectTopIssues(query string, issues []infra.Issue, channelID, threadTimestamp string) ([]model.Result, error) {
+func (s *SelectTopIssueService) SelectTopIssues(ctx context.Context, query string, issues []infra.Issue, channelID, threadTimestamp string) ([]model.Result, error) {
 	if len(issues) == 0 {
 		return []model.Result{}, nil
 	}
 
-	jiraendpoint := strings.TrimSuffix(os.Getenv("JIRA_ENDPOINT"), "/")
-	workspaceURL := os.Getenv("SLACK_WORKSPACE_URL")
+	jiraendpoint := s.jiraEndpoint
+	workspaceURL := s.workspaceURL
 
-	// 結果を格納するためのスライス
-	results := make([]model.Result, len(issues))
-	var mu sync.Mutex
+	// 期限を過ぎた場合は未評価の課題を打ち切り、評価済みの課題から結果を返す
+	selectCtx, cancel := s.withSelectTimeout(ctx)
+	defer cancel()
 
 	// 通知用のチャンネルとworkerを起動
-	ctx := context.Background()
 	notifyCh := make(chan notificationMessage, 100)
 	var notifyWg sync.WaitGroup
 	notifyWg.Add(1)
 	go s.notificationWorker(ctx, notifyCh, &notifyWg)
 
-	// エラーグループを使用して並列処理（セマフォで並列度を制限）
-	const maxConcurrency = 5
-	sem := semaphore.NewWeighted(maxConcurrency)
-	g, gctx := errgroup.WithContext(ctx)
-
-	// 各issueを並列で処理
-	for i, issue := range issues {
-		i, issue := i, issue // ループ変数をキャプチャ
-		g.Go(func() error {
-			// セマフォを取得（並列度を制限）
-			if err := sem.Acquire(gctx, 1); err != nil {
-				return err
-			}
-			defer sem.Release(1)
-
-			// 処理開始のログ出力
-			slog.Info("Issue processing started", slog.String("issue_key", issue.Key), slog.String("summary", issue.Fields.Summary))
-
-			// リトライ機能付きで処理
-			var result model.Result
-			startTime := time.Now()
+	// コストと時間を抑えるため処理対象の件数を制限する
+	issues, dropped := s.limitProcessIssues(ctx, query, issues)
+	if dropped > 0 {
+		notifyCh <- notificationMessage{
+			message:         s.message("select_narrowed", len(issues), dropped),
+			channelID:       channelID,
+			threadTimestamp: threadTimestamp,
+		}
+	}
 
-			retryErr := retry.Retry(3, 3*time.Second, func() error {
-				contentSummary := formatIssue(issue)
-				jiraURL := fmt.Sprintf("%s/browse/%s", jiraendpoint, issue.Key)
+	// 一次評価としてEmbeddingで候補を絞り込む
+	if s.twoStageRanking {
+		issues = s.rankByEmbedding(selectCtx, query, issues)
+	}
+	stage2Start := time.Now()
+	defer func() {
+		if s.twoStageRanking {
+			slog.InfoContext(ctx, "Stage 2 ranking completed",
+				slog.Int("issues", len(issues)),
+				slog.Duration("duration", time.Since(stage2Start)))
+		}
+	}()
 
-				// Slack検索
-				threads, err := s.slack.SearchThreads(jiraURL, channelID)
-				if err != nil {
-					return fmt.Errorf("failed to search threads: %w", err)
+	// 全件の結果を保持せず、表示し得る上位の結果のみヒープで保持する
+	top := newTopResults(s.maxResults, s.groupByThread)
+	low := newTopResults(maxLowSimilarityFallback, false)
+	// 分布の集計用に、除外した候補も含めて類似度を計算できたものを記録する
+	var similarities []float64
+	var mu sync.Mutex
+	// 課題キーごとの成功・失敗・リトライ回数
+	stats := &similarityStats{}
+	// SELECT_TIMEOUT により評価できなかった課題の数
+	var timedOut atomic.Int32
+
+	// issueをチャネルで流し込み、並列度の数のワーカーで逐次処理する
+	g, gctx := errgroup.WithContext(selectCtx)
+	issueCh := make(chan infra.Issue)
+	go func() {
+		defer close(issueCh)
+		for i, issue := range issues {
+			select {
+			case issueCh <- issue:
+			case <-gctx.Done():
+				// 期限切れで流し込めなかった課題は打ち切りとして数える
+				if selectCtx.Err() != nil && ctx.Err() == nil {
+					timedOut.Add(int32(len(issues) - i))
 				}
+				return
+			}
+		}
+	}()
+
+	// 課題単位の失敗は空の結果として継続し、問い合わせ全体のキャンセルのみエラーを返す（issueOutcome を参照）
+	process := func(issue infra.Issue) error {
+		switch classifyIssueError(ctx, selectCtx, nil) {
+		case issueAborted:
+			return ctx.Err()
+		case issueTimedOut:
+			timedOut.Add(1)
+			return nil
+		}
 
-				slackThreadMessages, err := s.slack.FormattedSearchThreads(threads)
+		// 処理開始のログ出力
+		slog.InfoContext(ctx, "Issue processing started", slog.String("issue_key", issue.Key), slog.String("summary", issue.Fields.Summary))
+
+		// リトライ機能付きで処理
+		var result model.Result
+		var slackSearchDuration, similarityDuration time.Duration
+		var threadMessages int
+		startTime := time.Now()
+
+		var attempts int
+		retryErr := retry.WithContext(selectCtx, 3, s.issueRetryDelay, func() error {
+			attempts++
+			contentSummary := formatIssue(issue)
+			jiraURL := fmt.Sprintf("%s/browse/%s", jiraendpoint, issue.Key)
+
+			// Slack検索
+			stepStart := time.Now()
+			threads, slackThreadMessages := s.searchSlackThreads(gctx, jiraURL, issue.Fields.Summary, channelID)
+			slackSearchDuration += time.Since(stepStart)
+			threadMessages = len(threads)
+
+			// トークン節約のため課題を要点に圧縮してから類似度計算に使う
+			stepStart = time.Now()
+			defer func() { similarityDuration += time.Since(stepStart) }()
+			similarityContent := contentSummary
+			if s.condenseBeforeSimilarity {
+				condensed, err := withTimeout(gctx, s.similarityTimeout, func(ctx context.Context) (string, error) {
+					return s.openAI.CondenseIssue(ctx, contentSummary)
+				})
 				if err != nil {
-					return fmt.Errorf("failed to format threads: %w", err)
+					return fmt.Errorf("failed to condense issue: %w", err)
 				}
+				similarityContent = condensed
+			}
 
-				// OpenAI類似度計算（最もエラーが起きやすい部分）
-				similarity, err := s.openAI.CalculateSimilarity(query, contentSummary, slackThreadMessages)
-				if err != nil {
-					return fmt.Errorf("failed to calculate similarity: %w", err)
-				}
+			// OpenAI類似度計算（最もエラーが起きやすい部分）
+			similarity, err := withTimeout(gctx, s.similarityTimeout, func(ctx context.Context) (float64, error) {
+				return s.openAI.CalculateSimilarity(ctx, issue.Key, query, similarityContent, slackThreadMessages)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to calculate similarity: %w", err)
+			}
+			similarity = s.applyThreadEngagement(ctx, issue.Key, similarity, threads)
+			mu.Lock()
+			similarities = append(similarities, similarity)
+			mu.Unlock()
 
-				// 類似度が0.3以下のものは除外
-				if similarity < 0.3 {
+			// 類似度がしきい値未満のものは除外
+			if similarity < SimilarityThreshold {
+				if !s.fallbackLowSimilarity {
 					result = model.Result{} // 空の結果
 					return nil
 				}
-
-				// 結果を構築
-				result = model.Result{
-					ID:             issue.ID,
-					Summary:        issue.Fields.Summary,
-					Description:    issue.GetDescription(),
-					URL:            jiraURL,
-					ContentSummary: contentSummary,
-					Similarity:     similarity,
-					SlackThread:    slackThreadMessages,
-				}
-
-				if len(threads) > 0 {
-					result.SlackThreadURL = fmt.Sprintf("%s/archives/%s/p%s", workspaceURL, threads[0].ChannelID, threads[0].Timestamp)
-				}
-
+				// しきい値以上が0件だった場合の保険表示の候補として残す
+				result = s.newResult(gctx, issue, jiraURL, contentSummary, threads, slackThreadMessages, workspaceURL)
+				result.Similarity = similarity
+				result.LowSimilarity = true
 				return nil
-			})
+			}
 
-			duration := time.Since(startTime)
+			// 結果を構築
+			result = s.newResult(gctx, issue, jiraURL, contentSummary, threads, slackThreadMessages, workspaceURL)
+			result.Similarity = similarity
+			return nil
+		})
 
-			if retryErr != nil {
-				// エラーログ出力
-				slog.Error("Issue processing failed",
+		duration := time.Since(startTime)
+		stats.record(issue.Key, attempts, retryErr)
+
+		if retryErr != nil {
+			switch classifyIssueError(ctx, selectCtx, retryErr) {
+			case issueAborted:
+				// 問い合わせ自体がキャンセルされた場合は残りの課題も処理せず全体を失敗させる
+				return fmt.Errorf("issue %s aborted: %w", issue.Key, ctx.Err())
+			case issueTimedOut:
+				// 期限切れで打ち切った課題はエラーとして通知しない
+				slog.WarnContext(ctx, "Issue processing timed out",
+					slog.String("issue_key", issue.Key),
+					slog.Duration("duration", duration))
+				timedOut.Add(1)
+				return nil
+			case issueFailed:
+				slog.ErrorContext(ctx, "Issue processing failed",
 					slog.String("issue_key", issue.Key),
 					slog.String("summary", issue.Fields.Summary),
 					slog.Duration("duration", duration),
 					slog.Any("error", retryErr))
 
-				// リトライエラーの場合はSlack通知のみ行い、エラー扱いにしない
+				// 課題単位の失敗はSlack通知のみ行い、空の結果として他の課題の処理を継続する
 				notifyCh <- notificationMessage{
-					message:         fmt.Sprintf("❌ 処理エラー: `%s` - %s (エラー: %v)", issue.Key, issue.Fields.Summary, retryErr),
+					message:         s.message("select_issue_error", issue.Key, issue.Fields.Summary, retryErr),
 					channelID:       channelID,
 					threadTimestamp: threadTimestamp,
+					unordered:       true,
 				}
-				// 空の結果を設定して処理を継続
 				result = model.Result{}
 			}
+		}
 
-			// 処理完了のログ出力
-			slog.Info("Issue processing completed",
-				slog.String("issue_key", issue.Key),
-				slog.String("summary", issue.Fields.Summary),
-				slog.Float64("similarity", result.Similarity),
-				slog.Duration("duration", duration))
-
-			// Slack通知: 処理完了（類似度と共に）
-			var completeMsg string
-			if result.Similarity < 0.3 {
-				completeMsg = fmt.Sprintf("⚪ 処理完了: `%s` - %s (類似度: %.2f - 除外)", issue.Key, issue.Fields.Summary, result.Similarity)
-			} else {
-				completeMsg = fmt.Sprintf("✅ 処理完了: `%s` - %s (類似度: %.2f)", issue.Key, issue.Fields.Summary, result.Similarity)
-			}
-			notifyCh <- notificationMessage{
-				message:         completeMsg,
-				channelID:       channelID,
-				threadTimestamp: threadTimestamp,
-			}
-
-			// 結果を格納
-			mu.Lock()
-			results[i] = result
-			mu.Unlock()
+		// 処理完了のログ出力
+		slog.InfoContext(ctx, "Issue processing completed",
+			slog.String("issue_key", issue.Key),
+			slog.String("summary", issue.Fields.Summary),
+			slog.Float64("similarity", result.Similarity),
+			slog.Int("attempts", attempts),
+			slog.Duration("duration", duration),
+			slog.Duration("slack_search_duration", slackSearchDuration),
+			slog.Duration("similarity_duration", similarityDuration),
+			slog.Duration("elapsed", logging.Elapsed(ctx)))
+
+		record := selectRecord{
+			Time:           startTime,
+			TraceID:        logging.TraceID(ctx),
+			UserID:         logging.UserID(ctx),
+			Query:          query,
+			IssueKey:       issue.Key,
+			Attempts:       attempts,
+			Summary:        issue.Fields.Summary,
+			Similarity:     result.Similarity,
+			Excluded:       result.ID == "",
+			LowSimilarity:  result.LowSimilarity,
+			HasThread:      threadMessages > 0,
+			ThreadMessages: threadMessages,
+			DurationMs:     duration.Milliseconds(),
+			SlackSearchMs:  slackSearchDuration.Milliseconds(),
+			SimilarityMs:   similarityDuration.Milliseconds(),
+		}
+		if retryErr != nil {
+			record.Error = retryErr.Error()
+		}
+		s.exporter.export(record)
+
+		// Slack通知: 処理完了（類似度と共に）
+		var completeMsg string
+		if result.Similarity < SimilarityThreshold {
+			completeMsg = s.message("select_issue_excluded", issue.Key, issue.Fields.Summary, result.Similarity)
+		} else {
+			completeMsg = s.message("select_issue_done", issue.Key, issue.Fields.Summary, result.Similarity)
+		}
+		completeMsg += s.elapsedSuffix(ctx)
+		notifyCh <- notificationMessage{
+			message:         completeMsg,
+			channelID:       channelID,
+			threadTimestamp: threadTimestamp,
+			unordered:       true,
+		}
 
+		// 結果を格納（空の結果は除外）
+		switch {
+		case result.ID == "":
+		case result.LowSimilarity:
+			low.add(result)
+		default:
+			top.add(result)
+		}
+		return nil
+	}
+	for range s.concurrency {
+		g.Go(func() error {
+			for issue := range issueCh {
+				if err := process(issue); err != nil {
+					return err
+				}
+			}
 			return nil
 		})
 	}
 
 	// 全てのgoroutineの完了を待つ
+	// process は問い合わせ全体のキャンセル時のみエラーを返すため、ここでのエラーは致命的なものに限られる
 	if err := g.Wait(); err != nil {
 		close(notifyCh)
 		notifyWg.Wait()
@@ -243,26 +718,77 @@ func (s *SelectTopIssueService) SelectTopIssues(query string, issues []infra.Iss
 	close(notifyCh)
 	notifyWg.Wait()
 
-	// 結果を収集（空の結果は除外）
-	var convIssues []model.Result
-	for _, result := range results {
-		if result.ID != "" {
-			convIssues = append(convIssues, result)
+	s.reportDistribution(ctx, similarities, channelID, threadTimestamp)
+	stats.log(ctx)
+	s.recordTimeout(ctx, len(issues), int(timedOut.Load()))
+
+	// 最も関連度が高い maxResults 件を選択
+	convIssues := top.sorted()
+	if len(convIssues) == 0 {
+		lowIssues := low.sorted()
+		if len(lowIssues) == 0 {
+			return []model.Result{}, nil
 		}
+		// しきい値以上が0件の場合のみ、最上位の候補を参考として返す
+		slog.InfoContext(ctx, "No issues above threshold, fallback to low similarity issues", slog.Int("count", len(lowIssues)))
+		s.attachSprints(ctx, lowIssues)
+		return lowIssues, nil
 	}
+	s.attachSprints(ctx, convIssues)
+	return convIssues, nil
+}
 
-	if len(convIssues) == 0 {
-		return []model.Result{}, nil
+// 選定後の結果にのみスプリント情報を付与する
+// Agile APIは課題ごとの呼び出しになるため、類似度で絞り込む前の候補全件には問い合わせない
+func (s *SelectTopIssueService) attachSprints(ctx context.Context, results []model.Result) {
+	for i := range results {
+		sprints, err := s.jira.FetchSprintInfo(ctx, results[i].Key)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch sprint info", slog.String("issue_key", results[i].Key), slog.Any("err", err))
+			continue
+		}
+		results[i].ContentSummary += sprintSection(sprints)
 	}
+}
 
-	// 類似度でソート
-	sort.Slice(convIssues, func(i, j int) bool {
-		return convIssues[i].Similarity > convIssues[j].Similarity
+// 通知に付ける問い合わせ開始からの経過時間（開始時刻が無い場合は付けない）
+func (s *SelectTopIssueService) elapsedSuffix(ctx context.Context) string {
+	elapsed := logging.Elapsed(ctx)
+	if elapsed == 0 {
+		return ""
+	}
+	return s.message("select_elapsed", elapsed.Seconds())
+}
+
+// 類似度でソート（同点の場合は課題キーで並べて表示順を安定させる）
+func sortResults(results []model.Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return lessIssueKey(results[i].Key, results[j].Key)
 	})
+}
 
-	// 最も関連度が高い5件を選択
-	if len(convIssues) < 5 {
-		return convIssues, nil
+// 課題キー（PROJ-123形式）をプロジェクト名、番号の順に比較する
+// 番号は数値として比較するため PROJ-9 は PROJ-10 より前になる
+func lessIssueKey(a, b string) bool {
+	aProject, aNum, aOK := splitIssueKey(a)
+	bProject, bNum, bOK := splitIssueKey(b)
+	if !aOK || !bOK || aProject != bProject {
+		return a < b
+	}
+	return aNum < bNum
+}
+
+func splitIssueKey(key string) (string, int, bool) {
+	idx := strings.LastIndex(key, "-")
+	if idx == -1 {
+		return "", 0, false
+	}
+	num, err := strconv.Atoi(key[idx+1:])
+	if err != nil {
+		return "", 0, false
 	}
-	return convIssues[:5], nil
+	return key[:idx], num, true
 }
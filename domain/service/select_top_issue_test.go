@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pyama86/jipcy/domain/infra"
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// 課題キーごとに類似度を返すスタブ
+type stubSimilarity struct {
+	similarity func(ctx context.Context, issueKey string) (float64, error)
+}
+
+func (s *stubSimilarity) CalculateSimilarity(ctx context.Context, issueKey, query, contentSummary, slackThreadMessages string) (float64, error) {
+	return s.similarity(ctx, issueKey)
+}
+
+func (s *stubSimilarity) CondenseIssue(ctx context.Context, contentSummary string) (string, error) {
+	return contentSummary, nil
+}
+
+func (s *stubSimilarity) CreateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	return nil, errors.New("not implemented")
+}
+
+// スレッドが見つからないSlack検索のスタブ
+type stubThreads struct{}
+
+func (stubThreads) SearchThreads(ctx context.Context, keyword, channelID string) ([]model.ThreadMessage, error) {
+	return nil, nil
+}
+
+func (stubThreads) SearchThreadsByKeywords(ctx context.Context, keywords []string, matchAll bool, channelID string, maxThreads int) ([]model.ThreadMessage, error) {
+	return nil, nil
+}
+
+func (stubThreads) FormattedSearchThreads(ctx context.Context, threads []model.ThreadMessage) (string, error) {
+	return "", nil
+}
+
+func (stubThreads) GetThreadPermalink(ctx context.Context, workspaceURL string, msg model.ThreadMessage) string {
+	return ""
+}
+
+// スプリント情報が無いJiraのスタブ
+type stubSprints struct{}
+
+func (stubSprints) FetchSprintInfo(ctx context.Context, key string) ([]infra.Sprint, error) {
+	return nil, nil
+}
+
+func newTestSelectService(similarity func(ctx context.Context, issueKey string) (float64, error)) *SelectTopIssueService {
+	return &SelectTopIssueService{
+		openAI:             &stubSimilarity{similarity: similarity},
+		slack:              stubThreads{},
+		jira:               stubSprints{},
+		jiraEndpoint:       "https://jira.example.com",
+		slackSearchTimeout: time.Second,
+		slackFormatTimeout: time.Second,
+		similarityTimeout:  time.Second,
+		issueRetryDelay:    time.Millisecond,
+		maxProcessIssues:   30,
+		maxResults:         MaxTopIssues,
+		concurrency:        2,
+	}
+}
+
+func testIssues(keys ...string) []infra.Issue {
+	issues := make([]infra.Issue, len(keys))
+	for i, key := range keys {
+		issues[i].ID = key
+		issues[i].Key = key
+		issues[i].Fields.Summary = "summary of " + key
+	}
+	return issues
+}
+
+func TestSelectTopIssuesContinuesOnIssueFailure(t *testing.T) {
+	svc := newTestSelectService(func(ctx context.Context, issueKey string) (float64, error) {
+		if issueKey == "PROJ-2" {
+			return 0, errors.New("similarity failed")
+		}
+		return 0.8, nil
+	})
+
+	results, err := svc.SelectTopIssues(context.Background(), "query", testIssues("PROJ-1", "PROJ-2", "PROJ-3"), "C1", "1.0")
+	if err != nil {
+		t.Fatalf("SelectTopIssues() error = %v", err)
+	}
+	var keys []string
+	for _, r := range results {
+		keys = append(keys, r.Key)
+	}
+	if len(keys) != 2 || keys[0] != "PROJ-1" || keys[1] != "PROJ-3" {
+		t.Errorf("SelectTopIssues() keys = %v, want [PROJ-1 PROJ-3]", keys)
+	}
+}
+
+func TestSelectTopIssuesFailsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := newTestSelectService(func(ctx context.Context, issueKey string) (float64, error) {
+		if issueKey == "PROJ-1" {
+			cancel()
+			return 0, ctx.Err()
+		}
+		return 0.8, nil
+	})
+
+	results, err := svc.SelectTopIssues(ctx, "query", testIssues("PROJ-1", "PROJ-2", "PROJ-3"), "C1", "1.0")
+	if err == nil {
+		t.Fatalf("SelectTopIssues() = %v, want error", results)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SelectTopIssues() error = %v, want context.Canceled", err)
+	}
+}
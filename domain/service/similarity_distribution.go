@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+// 候補全体の類似度の分布
+type similarityDistribution struct {
+	count          int
+	mean           float64
+	median         float64
+	max            float64
+	min            float64
+	aboveThreshold int
+}
+
+// newSimilarityDistribution は類似度を計算できた候補の分布を求める
+func newSimilarityDistribution(similarities []float64) similarityDistribution {
+	d := similarityDistribution{count: len(similarities)}
+	if d.count == 0 {
+		return d
+	}
+	sorted := append([]float64(nil), similarities...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+		if v >= SimilarityThreshold {
+			d.aboveThreshold++
+		}
+	}
+	d.mean = sum / float64(d.count)
+	d.min = sorted[0]
+	d.max = sorted[d.count-1]
+	if d.count%2 == 0 {
+		d.median = (sorted[d.count/2-1] + sorted[d.count/2]) / 2
+	} else {
+		d.median = sorted[d.count/2]
+	}
+	return d
+}
+
+func (d similarityDistribution) logAttrs() []any {
+	return []any{
+		slog.Int("count", d.count),
+		slog.Float64("mean", d.mean),
+		slog.Float64("median", d.median),
+		slog.Float64("max", d.max),
+		slog.Float64("min", d.min),
+		slog.Int("above_threshold", d.aboveThreshold),
+		slog.Float64("threshold", SimilarityThreshold),
+	}
+}
+
+func (d similarityDistribution) text(message Messages) string {
+	return message("select_distribution",
+		d.count, d.mean, d.median, d.max, d.min, SimilarityThreshold, d.aboveThreshold)
+}
+
+// 類似度の分布をログに出し、SHOW_DISTRIBUTION が有効な場合はSlackにも投稿する
+func (s *SelectTopIssueService) reportDistribution(ctx context.Context, similarities []float64, channelID, threadTimestamp string) {
+	d := newSimilarityDistribution(similarities)
+	if d.count == 0 {
+		return
+	}
+	slog.InfoContext(ctx, "Similarity distribution", d.logAttrs()...)
+	if !s.showDistribution {
+		return
+	}
+	if err := s.postNotification(notificationMessage{
+		message:         d.text(s.message),
+		channelID:       channelID,
+		threadTimestamp: threadTimestamp,
+	}); err != nil {
+		slog.ErrorContext(ctx, "Failed to post similarity distribution", slog.Any("err", err))
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// 課題キーごとの類似度計算の試行結果を集計する
+type similarityStats struct {
+	mu      sync.Mutex
+	entries []similarityStat
+}
+
+// 1課題分の試行結果
+type similarityStat struct {
+	issueKey string
+	// 試行回数（1回目を含む）
+	attempts int
+	err      error
+}
+
+func (s *similarityStats) record(issueKey string, attempts int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, similarityStat{issueKey: issueKey, attempts: attempts, err: err})
+}
+
+// 成功・失敗・リトライ回数の合計と、リトライまたは失敗した課題の内訳をログに出力する
+func (s *similarityStats) log(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var succeeded, failed, retries int
+	var details []any
+	for _, e := range s.entries {
+		if e.attempts > 1 {
+			retries += e.attempts - 1
+		}
+		if e.err != nil {
+			failed++
+		} else {
+			succeeded++
+		}
+		if e.attempts <= 1 && e.err == nil {
+			continue
+		}
+		attrs := []any{slog.Int("attempts", e.attempts)}
+		if e.err != nil {
+			attrs = append(attrs, slog.String("error", e.err.Error()))
+		}
+		details = append(details, slog.Group(e.issueKey, attrs...))
+	}
+
+	slog.InfoContext(ctx, "Similarity calculation summary",
+		slog.Int("succeeded", succeeded),
+		slog.Int("failed", failed),
+		slog.Int("retries", retries),
+		slog.Group("issues", details...))
+}
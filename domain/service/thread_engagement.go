@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// 返信数がこの件数に達したスレッドを最も活発に議論されたものとみなす
+const engagementSaturation = 20
+
+// スレッドごとの返信数（親メッセージを除く）のうち最大のもの
+func maxThreadReplies(threads []model.ThreadMessage) int {
+	counts := make(map[string]int)
+	maxReplies := 0
+	for _, msg := range threads {
+		key := msg.ChannelID + ":" + msg.ThreadTimestamp
+		if msg.Timestamp == msg.ThreadTimestamp {
+			continue
+		}
+		counts[key]++
+		maxReplies = max(maxReplies, counts[key])
+	}
+	return maxReplies
+}
+
+// engagementBonus は返信数に応じた加点を返す
+// 返信数の対数で緩やかに増やし、engagementSaturation 件以上で weight に達する
+func engagementBonus(replies int, weight float64) float64 {
+	if weight <= 0 || replies <= 0 {
+		return 0
+	}
+	ratio := math.Log1p(float64(replies)) / math.Log1p(engagementSaturation)
+	return weight * math.Min(ratio, 1)
+}
+
+// THREAD_ENGAGEMENT_WEIGHT が設定されていれば、活発に議論されたSlackスレッドを持つ課題の類似度を加点する
+// 重みが0の場合は類似度をそのまま返す
+func (s *SelectTopIssueService) applyThreadEngagement(ctx context.Context, issueKey string, similarity float64, threads []model.ThreadMessage) float64 {
+	if s.threadEngagementWeight <= 0 {
+		return similarity
+	}
+	replies := maxThreadReplies(threads)
+	bonus := engagementBonus(replies, s.threadEngagementWeight)
+	adjusted := math.Min(similarity+bonus, 1)
+	slog.InfoContext(ctx, "Thread engagement applied",
+		slog.String("issue_key", issueKey),
+		slog.Float64("weight", s.threadEngagementWeight),
+		slog.Int("replies", replies),
+		slog.Float64("bonus", bonus),
+		slog.Float64("similarity", similarity),
+		slog.Float64("adjusted", adjusted))
+	return adjusted
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// 課題のサマリから検索に使うキーワードの最大数
+const maxSummaryKeywords = 5
+
+// 課題のサマリからSlack検索用のキーワードを抽出する
+// 記号や空白で区切り、1文字の語や重複は除外する
+func summaryKeywords(summary string) []string {
+	fields := strings.FieldsFunc(summary, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-' && r != '_'
+	})
+
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, f := range fields {
+		f = strings.Trim(f, "-_")
+		if utf8.RuneCountInString(f) < 2 || seen[strings.ToLower(f)] {
+			continue
+		}
+		seen[strings.ToLower(f)] = true
+		keywords = append(keywords, f)
+		if len(keywords) >= maxSummaryKeywords {
+			break
+		}
+	}
+	return keywords
+}
+
+// スレッド単位にまとめたメッセージとスコア
+type scoredThread struct {
+	messages []model.ThreadMessage
+	score    float64
+}
+
+// URL検索とキーワード検索の結果をスレッド単位でマージし、スコア順に並べる
+// URLが貼られたスレッドは常にキーワード検索のスレッドより優先し、
+// キーワード検索のスレッドは合計が maxThreads に達するまでしか追加しない
+func mergeThreads(urlThreads, keywordThreads []model.ThreadMessage, keywords []string, maxThreads int) []model.ThreadMessage {
+	var merged []scoredThread
+	indexByKey := make(map[string]int)
+
+	add := func(messages []model.ThreadMessage, base float64) {
+		var order []string
+		groups := make(map[string][]model.ThreadMessage)
+		for _, msg := range messages {
+			key := msg.ChannelID + ":" + msg.ThreadTimestamp
+			if _, ok := indexByKey[key]; ok {
+				continue
+			}
+			if _, ok := groups[key]; !ok {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], msg)
+		}
+		for _, key := range order {
+			indexByKey[key] = len(merged)
+			merged = append(merged, scoredThread{
+				messages: groups[key],
+				score:    base + keywordScore(groups[key], keywords),
+			})
+		}
+	}
+	add(urlThreads, 1)
+	urlCount := len(merged)
+	add(keywordThreads, 0)
+
+	// キーワード検索由来のスレッドのみ、キーワードの一致率で並べて上限で切り詰める
+	extra := merged[urlCount:]
+	sort.SliceStable(extra, func(i, j int) bool {
+		return extra[i].score > extra[j].score
+	})
+	if limit := maxThreads - urlCount; limit < len(extra) {
+		if limit < 0 {
+			limit = 0
+		}
+		extra = extra[:limit]
+	}
+
+	var threads []model.ThreadMessage
+	for _, t := range append(merged[:urlCount], extra...) {
+		threads = append(threads, t.messages...)
+	}
+	return threads
+}
+
+// スレッド内に含まれるキーワードの割合を返す
+func keywordScore(messages []model.ThreadMessage, keywords []string) float64 {
+	if len(keywords) == 0 {
+		return 0
+	}
+	var texts []string
+	for _, msg := range messages {
+		texts = append(texts, msg.Text)
+	}
+	text := strings.ToLower(strings.Join(texts, "\n"))
+
+	var hit int
+	for _, keyword := range keywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(keywords))
+}
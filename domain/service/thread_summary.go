@@ -0,0 +1,98 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pyama86/jipcy/domain/model"
+)
+
+// このメッセージ数以下のスレッドは要約せずにそのまま使う
+const threadSummarizeMinMessages = 10
+
+// 要約後に残す1スレッドあたりのメッセージ数
+const threadSummarizeKeepMessages = 8
+
+// 解決・対応状況を表す発言に含まれやすいキーワードと重み
+var threadImportantKeywords = map[string]float64{
+	"解決":         3,
+	"対応完了":       3,
+	"完了しました":     2,
+	"修正しました":     2,
+	"原因":         2,
+	"回避":         2,
+	"暫定":         1,
+	"再発":         1,
+	"resolved":   3,
+	"fixed":      2,
+	"root cause": 2,
+	"workaround": 2,
+	"deployed":   1,
+}
+
+// スレッドごとに重要な発言を優先して抽出する
+// 質問にあたる先頭の発言と最新の発言は常に残し、残りは解決に関するキーワードの重みが高い順に選ぶ
+// 抽出した発言は元の順序のまま返す
+func summarizeThread(messages []model.ThreadMessage) []model.ThreadMessage {
+	var order []string
+	byThread := make(map[string][]model.ThreadMessage)
+	for _, msg := range messages {
+		key := msg.ChannelID + ":" + msg.ThreadTimestamp
+		if _, ok := byThread[key]; !ok {
+			order = append(order, key)
+		}
+		byThread[key] = append(byThread[key], msg)
+	}
+
+	var summarized []model.ThreadMessage
+	for _, key := range order {
+		summarized = append(summarized, importantMessages(byThread[key])...)
+	}
+	return summarized
+}
+
+func importantMessages(thread []model.ThreadMessage) []model.ThreadMessage {
+	if len(thread) <= threadSummarizeMinMessages {
+		return thread
+	}
+
+	last := len(thread) - 1
+	candidates := make([]int, 0, last-1)
+	for i := 1; i < last; i++ {
+		candidates = append(candidates, i)
+	}
+	scores := make([]float64, len(thread))
+	for _, i := range candidates {
+		scores[i] = importanceScore(thread[i].Text)
+	}
+	// 同じ重みの場合は新しい発言を優先する
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if scores[candidates[a]] != scores[candidates[b]] {
+			return scores[candidates[a]] > scores[candidates[b]]
+		}
+		return candidates[a] > candidates[b]
+	})
+
+	keep := map[int]bool{0: true, last: true}
+	for _, i := range candidates[:threadSummarizeKeepMessages-2] {
+		keep[i] = true
+	}
+	picked := make([]model.ThreadMessage, 0, len(keep))
+	for i, msg := range thread {
+		if keep[i] {
+			picked = append(picked, msg)
+		}
+	}
+	return picked
+}
+
+func importanceScore(text string) float64 {
+	text = strings.ToLower(text)
+	var score float64
+	for keyword, weight := range threadImportantKeywords {
+		if strings.Contains(text, keyword) {
+			score += weight
+		}
+	}
+	return score
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 複数のgoroutineで共有するトークンバケット
+// interval ごとに1トークン補充し、最大 capacity 個までまとめて送信できる
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	interval time.Duration
+	last     time.Time
+	// rate limitを受けた場合に、この時刻まで全体の送信を止める
+	pausedUntil time.Time
+}
+
+func newTokenBucket(capacity int, interval time.Duration) *tokenBucket {
+	return &tokenBucket{
+		tokens:   float64(capacity),
+		capacity: float64(capacity),
+		interval: interval,
+		last:     time.Now(),
+	}
+}
+
+// トークンを1つ取得できるまで待つ
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		var delay time.Duration
+		if now.Before(b.pausedUntil) {
+			delay = b.pausedUntil.Sub(now)
+		} else {
+			b.refill(now)
+			if b.tokens >= 1 {
+				b.tokens--
+				b.mu.Unlock()
+				return nil
+			}
+			delay = time.Duration((1 - b.tokens) * float64(b.interval))
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// 指定した期間、全体の送信を止める
+func (b *tokenBucket) pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+	// 停止明けに溜まったトークンで一斉に送らないよう、補充は停止明けから数える
+	b.tokens = 0
+	b.last = b.pausedUntil
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !now.After(b.last) {
+		return
+	}
+	b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/pyama86/jipcy/urlutil"
+)
+
+// 類似度の上位 limit 件の結果のみを保持する
+// 全件を保持しないよう、上限を超えたら最も類似度の低い結果から捨てる
+type topResults struct {
+	mu    sync.Mutex
+	limit int
+	// true の場合は同じSlackスレッドに紐づく結果を関連課題としてまとめる
+	groupByThread bool
+	items         resultHeap
+}
+
+func newTopResults(limit int, groupByThread bool) *topResults {
+	return &topResults{limit: limit, groupByThread: groupByThread}
+}
+
+// 結果を追加する。同じ課題を指す結果は類似度の高い方を残す
+func (t *topResults) add(result model.Result) {
+	result.URL = urlutil.Normalize(result.URL)
+	result.SlackThreadURL = urlutil.Normalize(result.SlackThreadURL)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, item := range t.items {
+		if result.URL != "" && item.URL == result.URL {
+			if worseResult(item, result) {
+				t.items[i] = result
+				heap.Fix(&t.items, i)
+			}
+			return
+		}
+		if t.groupByThread && result.SlackThreadURL != "" && item.SlackThreadURL == result.SlackThreadURL {
+			t.items[i] = mergeThread(item, result)
+			heap.Fix(&t.items, i)
+			return
+		}
+	}
+
+	if len(t.items) < t.limit {
+		heap.Push(&t.items, result)
+		return
+	}
+	if len(t.items) > 0 && worseResult(t.items[0], result) {
+		t.items[0] = result
+		heap.Fix(&t.items, 0)
+	}
+}
+
+// 類似度の高い順に並べた結果を返す
+func (t *topResults) sorted() []model.Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	results := append([]model.Result(nil), t.items...)
+	sortResults(results)
+	return results
+}
+
+// 同じスレッドの結果のうち類似度の高い方を代表にし、もう一方を関連課題にする
+func mergeThread(a, b model.Result) model.Result {
+	if worseResult(a, b) {
+		a, b = b, a
+	}
+	a.RelatedIssues = append(a.RelatedIssues, model.RelatedIssue{
+		ID:         b.ID,
+		Key:        b.Key,
+		Summary:    b.Summary,
+		URL:        b.URL,
+		Similarity: b.Similarity,
+	})
+	a.RelatedIssues = append(a.RelatedIssues, b.RelatedIssues...)
+	b.RelatedIssues = nil
+	sort.SliceStable(a.RelatedIssues, func(i, j int) bool {
+		if a.RelatedIssues[i].Similarity != a.RelatedIssues[j].Similarity {
+			return a.RelatedIssues[i].Similarity > a.RelatedIssues[j].Similarity
+		}
+		return lessIssueKey(a.RelatedIssues[i].Key, a.RelatedIssues[j].Key)
+	})
+	return a
+}
+
+// a が b より後に表示される場合に true を返す（sortResults と同じ順序）
+func worseResult(a, b model.Result) bool {
+	if a.Similarity != b.Similarity {
+		return a.Similarity < b.Similarity
+	}
+	return lessIssueKey(b.Key, a.Key)
+}
+
+// 最も類似度の低い結果を先頭に保持するヒープ
+type resultHeap []model.Result
+
+func (h resultHeap) Len() int           { return len(h) }
+func (h resultHeap) Less(i, j int) bool { return worseResult(h[i], h[j]) }
+func (h resultHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *resultHeap) Push(x any) { *h = append(*h, x.(model.Result)) }
+
+func (h *resultHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
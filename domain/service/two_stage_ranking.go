@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pyama86/jipcy/domain/infra"
+	"golang.org/x/sync/errgroup"
+)
+
+// Embeddingによる一次評価で問い合わせとのコサイン類似度が高い上位 stage1TopN 件に絞り込む
+// Embeddingの取得に失敗した場合は絞り込まずに全件を返す
+func (s *SelectTopIssueService) rankByEmbedding(ctx context.Context, query string, issues []infra.Issue) []infra.Issue {
+	if len(issues) <= s.stage1TopN {
+		return issues
+	}
+	startTime := time.Now()
+
+	scores, err := s.embeddingScores(ctx, query, issues)
+	if err != nil {
+		slog.WarnContext(ctx, "Stage 1 ranking failed, fallback to all issues", slog.Any("err", err))
+		return issues
+	}
+
+	ranked := make([]int, len(issues))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return scores[ranked[a]] > scores[ranked[b]]
+	})
+
+	selected := make([]infra.Issue, 0, s.stage1TopN)
+	for _, i := range ranked[:s.stage1TopN] {
+		selected = append(selected, issues[i])
+	}
+
+	// Chatでの評価を省略できた件数をコスト削減効果として出力する
+	skipped := len(issues) - len(selected)
+	slog.InfoContext(ctx, "Stage 1 ranking completed",
+		slog.Int("issues", len(issues)),
+		slog.Int("selected", len(selected)),
+		slog.Int("skipped_chat_calls", skipped),
+		slog.Float64("chat_call_reduction", float64(skipped)/float64(len(issues))),
+		slog.Duration("duration", time.Since(startTime)))
+	return selected
+}
+
+// 処理対象の課題を maxProcessIssues 件に絞り込み、絞り込んだ課題と除外した件数を返す
+// TWO_STAGE_RANKING が有効な場合はEmbeddingのスコア順、それ以外は更新日時の新しい順に残す
+func (s *SelectTopIssueService) limitProcessIssues(ctx context.Context, query string, issues []infra.Issue) ([]infra.Issue, int) {
+	if len(issues) <= s.maxProcessIssues {
+		return issues, 0
+	}
+
+	ranked := make([]infra.Issue, len(issues))
+	copy(ranked, issues)
+	var scores []float64
+	if s.twoStageRanking {
+		var err error
+		scores, err = s.embeddingScores(ctx, query, issues)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to rank issues by embedding, fallback to updated time", slog.Any("err", err))
+		}
+	}
+	if scores != nil {
+		order := make([]int, len(issues))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return scores[order[a]] > scores[order[b]]
+		})
+		for i, idx := range order {
+			ranked[i] = issues[idx]
+		}
+	} else {
+		sort.SliceStable(ranked, func(a, b int) bool {
+			return ranked[a].GetUpdated().After(ranked[b].GetUpdated())
+		})
+	}
+
+	dropped := len(ranked) - s.maxProcessIssues
+	slog.InfoContext(ctx, "Limited issues to process",
+		slog.Int("issues", len(issues)),
+		slog.Int("max_process_issues", s.maxProcessIssues),
+		slog.Int("dropped", dropped))
+	return ranked[:s.maxProcessIssues], dropped
+}
+
+// 問い合わせと各課題のEmbeddingのコサイン類似度を並列で計算する
+func (s *SelectTopIssueService) embeddingScores(ctx context.Context, query string, issues []infra.Issue) ([]float64, error) {
+	queryEmbedding, err := s.openAI.CreateEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query embedding: %w", err)
+	}
+
+	scores := make([]float64, len(issues))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(5)
+	for i, issue := range issues {
+		i, issue := i, issue // ループ変数をキャプチャ
+		g.Go(func() error {
+			embedding, err := s.openAI.CreateEmbedding(gctx, formatIssue(issue))
+			if err != nil {
+				return fmt.Errorf("failed to create embedding for %s: %w", issue.Key, err)
+			}
+			scores[i] = cosineSimilarity(queryEmbedding, embedding)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// トピック・説明から拾うプロジェクトキーの候補
+var channelProjectKeyPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+\b`)
+
+// プロンプトに渡すトピック・説明の最大文字数
+const maxChannelContextLength = 500
+
+// USE_CHANNEL_CONTEXT が有効な場合、チャンネルのトピック・説明を検索クエリ生成のヒントとして返す
+// 取得できない場合やトピック・説明が無い場合は空を返す
+func (h *Handler) channelContext(channelID string) string {
+	if !h.cfg.Slack.UseChannelContext {
+		return ""
+	}
+	channel, err := h.slack.GetChannelInfo(channelID)
+	if err != nil {
+		slog.WarnContext(h.ctx, "Failed to get channel info for context", slog.Any("err", err))
+		return ""
+	}
+
+	var lines []string
+	if topic := strings.TrimSpace(channel.Topic.Value); topic != "" {
+		lines = append(lines, "トピック: "+truncateRunes(topic, maxChannelContextLength))
+	}
+	if purpose := strings.TrimSpace(channel.Purpose.Value); purpose != "" {
+		lines = append(lines, "説明: "+truncateRunes(purpose, maxChannelContextLength))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// 検索対象のプロジェクトキーのうち、トピック・説明に書かれているもの
+	projectKeys := h.cfg.Jira.ProjectKeys()
+	var mentioned []string
+	for _, key := range channelProjectKeyPattern.FindAllString(strings.Join(lines, "\n"), -1) {
+		if slices.Contains(projectKeys, key) && !slices.Contains(mentioned, key) {
+			mentioned = append(mentioned, key)
+		}
+	}
+	if len(mentioned) > 0 {
+		lines = append(lines, fmt.Sprintf("記載されているプロジェクトキー: %s（優先して検索対象にしてください）", strings.Join(mentioned, ", ")))
+	}
+
+	hint := strings.Join(lines, "\n")
+	slog.DebugContext(h.ctx, "Channel context", slog.String("hint", hint))
+	return hint
+}
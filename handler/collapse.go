@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/slack-go/slack"
+)
+
+// 課題を投稿するスレッドのタイムスタンプを返す
+// SLACK_COLLAPSE_RESULTS が有効な場合は「類似課題N件」の親メッセージをチャンネルに投稿し、そのスレッドに課題をまとめる
+// 問い合わせのスレッドには親メッセージへのリンクを投稿する。親メッセージを投稿できなかった場合は従来通り問い合わせのスレッドを返す
+// 親メッセージには最初のページの課題のみを載せ、続きのページに残る件数は「あとN件」として添える
+func (h *Handler) resultThread(channelID, ts string, results []model.Result) string {
+	if !h.cfg.Slack.CollapseResults || len(results) == 0 {
+		return ts
+	}
+
+	page, rest := h.splitPage(results)
+	lines := []string{h.messages.get("collapsed_header", len(page))}
+	for _, issue := range page {
+		lines = append(lines, h.messages.get("collapsed_item", issue.URL, issue.Key, issue.Summary, issue.Similarity))
+	}
+	if len(rest) > 0 {
+		lines = append(lines, h.messages.get("collapsed_remaining", len(rest), min(len(rest), h.cfg.Handler.ResultPageSize)))
+	}
+	_, parentTS, err := h.slackClient.PostMessage(
+		channelID,
+		slack.MsgOptionText(strings.Join(lines, "\n"), false),
+		slack.MsgOptionLinkNames(false),
+	)
+	if err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post collapsed results, fallback to flat results", slog.Any("err", err))
+		return ts
+	}
+
+	permalink, err := h.slackClient.GetPermalink(&slack.PermalinkParameters{Channel: channelID, Ts: parentTS})
+	if err != nil {
+		slog.WarnContext(h.ctx, "Failed to get permalink of collapsed results", slog.Any("err", err))
+		return parentTS
+	}
+	if _, _, err := h.slackClient.PostMessage(
+		channelID,
+		slack.MsgOptionText(h.messages.get("collapsed_link", permalink), false),
+		slack.MsgOptionTS(ts),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+	}
+	return parentTS
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 同一ユーザー・同一チャンネルから短時間に連投された問い合わせを1つにまとめる
+type debouncer struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	pending map[string]*pendingInquiry
+}
+
+// まとめる前の問い合わせ
+// 応答は最初の投稿のスレッドに返す
+type pendingInquiry struct {
+	channelID string
+	userID    string
+	ts        string
+	texts     []string
+	timer     *time.Timer
+	// タイマーが発火済みで、以降の投稿をまとめられない場合は true
+	fired bool
+}
+
+// DEBOUNCE_MS の間隔からデバウンスを生成する。0 の場合は nil を返し、即時処理とする
+func newDebouncer(delay time.Duration) *debouncer {
+	if delay <= 0 {
+		return nil
+	}
+	return &debouncer{
+		delay:   delay,
+		pending: make(map[string]*pendingInquiry),
+	}
+}
+
+// 問い合わせをバッファし、最後の投稿から delay の間に次の投稿が無ければ結合して fn を呼び出す
+func (d *debouncer) add(channelID, userID, text, ts string, fn func(channelID, userID, text, ts string)) {
+	key := channelID + ":" + userID
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 発火済み、またはロック待ちで発火直前のタイマーにはまとめず、新しい問い合わせとして扱う
+	if p, ok := d.pending[key]; ok && !p.fired && p.timer.Stop() {
+		p.texts = append(p.texts, text)
+		p.timer.Reset(d.delay)
+		slog.Info("Inquiry debounced", slog.String("channel", channelID), slog.String("user", userID), slog.Int("messages", len(p.texts)))
+		return
+	}
+
+	p := &pendingInquiry{channelID: channelID, userID: userID, ts: ts, texts: []string{text}}
+	p.timer = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		p.fired = true
+		// 発火を待つ間に新しい問い合わせに置き換わっていた場合は消さない
+		if d.pending[key] == p {
+			delete(d.pending, key)
+		}
+		text := strings.Join(p.texts, "\n")
+		d.mu.Unlock()
+		fn(p.channelID, p.userID, text, p.ts)
+	})
+	d.pending[key] = p
+}
+
+// デバウンスが有効な場合はバッファし、無効な場合は即時に問い合わせを処理する
+func (h *Handler) dispatchInquiry(channelID, userID, messageText, ts string) {
+	if h.debouncer == nil {
+		h.handleInquiry(channelID, userID, messageText, ts)
+		return
+	}
+	h.debouncer.add(channelID, userID, messageText, ts, h.handleInquiry)
+}
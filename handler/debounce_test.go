@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// debouncer に渡した問い合わせを記録する
+type debounceRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *debounceRecorder) fn(_, _, text, _ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, text)
+}
+
+func (r *debounceRecorder) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// 呼び出し回数が want になるまで待つ
+func (r *debounceRecorder) wait(t *testing.T, want int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := r.snapshot(); len(calls) >= want {
+			return calls
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("fn was called %d times, want %d", len(r.snapshot()), want)
+	return nil
+}
+
+func TestDebouncerBatches(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	d := newDebouncer(delay)
+	r := &debounceRecorder{}
+
+	d.add("C1", "U1", "first", "1", r.fn)
+	d.add("C1", "U1", "second", "2", r.fn)
+	calls := r.wait(t, 1)
+	if calls[0] != "first\nsecond" {
+		t.Fatalf("first batch = %q", calls[0])
+	}
+
+	d.add("C1", "U1", "third", "3", r.fn)
+	r.wait(t, 2)
+	time.Sleep(3 * delay)
+	calls = r.snapshot()
+	if len(calls) != 2 || calls[1] != "third" {
+		t.Fatalf("calls = %q, want two batches", calls)
+	}
+}
+
+// 発火と同時刻に届いた投稿も、いずれか1回の呼び出しにだけ含まれることを確認する
+func TestDebouncerDeliversEachMessageOnceAroundDelay(t *testing.T) {
+	const delay = 2 * time.Millisecond
+	d := newDebouncer(delay)
+	r := &debounceRecorder{}
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		d.add("C1", "U1", fmt.Sprintf("m%d", i), fmt.Sprint(i), r.fn)
+		// 遅延の前後に散らして送る
+		time.Sleep(delay/2 + time.Duration(i%3)*delay/2)
+	}
+	time.Sleep(20 * delay)
+
+	seen := make(map[string]int)
+	for _, call := range r.snapshot() {
+		for _, text := range strings.Split(call, "\n") {
+			seen[text]++
+		}
+	}
+	for i := 0; i < n; i++ {
+		if got := seen[fmt.Sprintf("m%d", i)]; got != 1 {
+			t.Errorf("m%d delivered %d times, want 1", i, got)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"log/slog"
+	"time"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+)
+
+// 重複配信を検出するために受信済みのイベントを保持する期間
+// Slackの再送は数分以内に行われるため、それを十分に上回る期間とする
+const eventDedupeTTL = 10 * time.Minute
+
+func newEventDedupe() *ttlcache.Cache[string, struct{}] {
+	cache := ttlcache.New(ttlcache.WithTTL[string, struct{}](eventDedupeTTL))
+	go cache.Start()
+	return cache
+}
+
+// isDuplicateEvent は同じイベントを既に受信していれば true を返す
+// client_msg_id があればそれを、無ければ event_ts とユーザーをキーにする
+// 同じ発言に app_mention と message の両方が届くため、イベントの種類もキーに含める
+func (h *Handler) isDuplicateEvent(eventType, clientMsgID, eventTS, userID string) bool {
+	key := eventType + ":" + clientMsgID
+	if clientMsgID == "" {
+		key = eventType + ":" + eventTS + ":" + userID
+	}
+	if _, found := h.seenEvents.GetOrSet(key, struct{}{}); found {
+		slog.InfoContext(h.ctx, "Duplicate event ignored",
+			slog.String("type", eventType),
+			slog.String("client_msg_id", clientMsgID),
+			slog.String("event_ts", eventTS),
+			slog.String("user", userID))
+		return true
+	}
+	return false
+}
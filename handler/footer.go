@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/pyama86/jipcy/domain/infra"
+	"github.com/pyama86/jipcy/domain/service"
+	"github.com/slack-go/slack"
+)
+
+// フッターに表示するJQL1件あたりの最大文字数
+const maxFooterQueryLength = 150
+
+// 問い合わせの検索条件と件数
+type inquiryFooter struct {
+	queries []string
+	fetched int
+	total   int
+	shown   int
+}
+
+// 文字数制限を超えないよう要点のみのテキストにする
+func (h *Handler) footerText(f inquiryFooter) string {
+	queries := make([]string, 0, len(f.queries))
+	for _, q := range f.queries {
+		queries = append(queries, "`"+truncateRunes(q, maxFooterQueryLength)+"`")
+	}
+	channels := h.messages.get("footer_all_channels")
+	if len(h.cfg.Slack.SearchAllowChannels) > 0 {
+		channels = strings.Join(h.cfg.Slack.SearchAllowChannels, ", ")
+	}
+	text := h.messages.get("footer", strings.Join(queries, " / "), channels, f.fetched, h.totalText(f.total, f.fetched), f.shown, service.SimilarityThreshold)
+	return truncateRunes(text, maxSectionTextLength)
+}
+
+// 総件数の表示。取得できなかった場合は取得件数以上であることを示す
+func (h *Handler) totalText(total, fetched int) string {
+	if total == infra.TotalUnknown {
+		return h.messages.get("total_at_least", fetched)
+	}
+	return h.messages.get("total_count", total)
+}
+
+// 問い合わせの末尾に検索条件と件数のフッターを投稿する
+func (h *Handler) postFooter(channelID, ts string, f inquiryFooter) {
+	if _, _, err := h.slackClient.PostMessage(
+		channelID,
+		slack.MsgOptionBlocks(
+			slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", h.footerText(f), false, false)),
+		),
+		slack.MsgOptionTS(ts),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+	}
+}
+
+// 最大文字数を超える場合は末尾を省略する
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
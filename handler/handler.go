@@ -8,38 +8,89 @@ import (
 	"strings"
 	"time"
 
+	ttlcache "github.com/jellydator/ttlcache/v3"
+	"github.com/pyama86/jipcy/config"
 	"github.com/pyama86/jipcy/domain/infra"
+	"github.com/pyama86/jipcy/domain/model"
 	"github.com/pyama86/jipcy/domain/service"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
 	"github.com/songmu/retry"
-	"golang.org/x/sync/errgroup"
 )
 
 type Handler struct {
+	cfg         *config.Config
 	slack       *infra.Slack
 	jira        *infra.Jira
 	openAI      *infra.OpenAI
+	webhook     *infra.Webhook
 	slackClient *slack.Client
 	botID       string
+	stats       *usageStats
+	messages    messages
+	statusEmoji statusEmojiMap
+	resultCache *service.ResultCache
+	// ユーザーごとの直近の問い合わせ
+	conversation *service.ConversationMemory
+	// issueごとの処理結果の出力先
+	exporter *service.SelectExporter
+	// 言語ごとのメッセージ定義
+	messageSets map[string]messages
+	// 問い合わせしたユーザーのlocaleから決定した応答言語（空の場合は既定の言語）
+	lang string
+	// false の場合は検索クエリ・件数などの途中経過を通知しない
+	verboseSteps bool
+	// true の場合は最終結果の末尾に処理時間を表示する
+	showTiming bool
+	// true の場合は要約をストリーミングで生成し、投稿済みの結果を逐次更新する
+	streamSummary bool
+	// ストリーミング中に結果を更新する間隔
+	streamInterval time.Duration
+	// 連投された問い合わせをまとめる。nil の場合は即時処理する
+	debouncer *debouncer
+	// 問い合わせ単位のcontext（トレースIDを保持し、ログに付与する）
+	ctx context.Context
+	// エラー時の再試行ボタンで再実行する問い合わせ文（空の場合はボタンを付けない）
+	retryText string
+	// true の場合は受付・途中経過・処理時間などを通知せず、最終結果とエラーのみ投稿する
+	quiet bool
+	// 「次のN件を表示」ボタンで投稿する未表示の結果
+	pages *ttlcache.Cache[string, pageSession]
+	// 重複配信を無視するために受信済みのイベント
+	seenEvents *ttlcache.Cache[string, struct{}]
 }
 
-func NewHandler(slack *infra.Slack, jira *infra.Jira, openAI *infra.OpenAI) *Handler {
+func NewHandler(cfg *config.Config, slack *infra.Slack, jira *infra.Jira, openAI *infra.OpenAI, webhook *infra.Webhook) *Handler {
 	return &Handler{
-		slack:  slack,
-		jira:   jira,
-		openAI: openAI,
+		cfg:            cfg,
+		slack:          slack,
+		jira:           jira,
+		openAI:         openAI,
+		webhook:        webhook,
+		stats:          newUsageStats(),
+		messages:       loadMessages(defaultLanguage(cfg), cfg.Handler.MessagesFile),
+		messageSets:    loadMessageSets(cfg.Handler.MessagesFile),
+		statusEmoji:    loadStatusEmojiMap(cfg.Handler.StatusEmojiMap),
+		resultCache:    service.NewResultCache(cfg),
+		conversation:   service.NewConversationMemory(cfg),
+		exporter:       service.NewSelectExporter(cfg),
+		verboseSteps:   cfg.Handler.VerboseSteps,
+		showTiming:     cfg.Handler.ShowTiming,
+		streamSummary:  cfg.Handler.SummaryStream,
+		streamInterval: cfg.Handler.SummaryStreamInterval,
+		debouncer:      newDebouncer(cfg.Handler.Debounce),
+		ctx:            context.Background(),
+		pages:          newPageSessions(),
+		seenEvents:     newEventDedupe(),
 	}
 }
 
+// Handle はSLACK_MODE に応じてSocket ModeまたはHTTP Events APIでイベントを受信する
 func (h *Handler) Handle() error {
 	webApi := slack.New(
-		os.Getenv("SLACK_BOT_TOKEN"),
-		slack.OptionAppLevelToken(os.Getenv("SLACK_APP_TOKEN")),
-	)
-	socketMode := socketmode.New(
-		webApi,
+		h.cfg.Slack.BotToken,
+		slack.OptionAppLevelToken(h.cfg.Slack.AppToken),
 	)
 	authTest, authTestErr := webApi.AuthTest()
 	if authTestErr != nil {
@@ -48,6 +99,21 @@ func (h *Handler) Handle() error {
 	}
 	h.botID = authTest.UserID
 	h.slackClient = webApi
+	if err := h.startStatsScheduler(); err != nil {
+		return err
+	}
+
+	if h.cfg.Slack.Mode == config.SlackModeHTTP {
+		return h.serveHTTP()
+	}
+	return h.runSocketMode(webApi)
+}
+
+// Socket Modeでイベントを受信する
+func (h *Handler) runSocketMode(webApi *slack.Client) error {
+	socketMode := socketmode.New(
+		webApi,
+	)
 	go func() {
 		for envelope := range socketMode.Events {
 			switch envelope.Type {
@@ -55,20 +121,20 @@ func (h *Handler) Handle() error {
 				socketMode.Ack(*envelope.Request)
 				eventPayload, ok := envelope.Data.(slackevents.EventsAPIEvent)
 				if !ok {
-					slog.Error("Failed to cast to EventsAPIEvent")
+					slog.ErrorContext(h.ctx, "Failed to cast to EventsAPIEvent")
 					continue
 				}
-
-				switch eventPayload.Type {
-				case slackevents.CallbackEvent:
-					innerEvent := eventPayload.InnerEvent
-					switch ev := innerEvent.Data.(type) {
-					case *slackevents.AppMentionEvent:
-						h.handleMention(ev)
-					default:
-						socketMode.Debugf("Skipped: %v", envelope.Type)
-					}
+				// Ack済みのイベントは受信ループを止めないよう非同期に処理する
+				h.runAsync("event", func() { h.handleEvent(eventPayload) })
+			case socketmode.EventTypeInteractive:
+				// リンクボタンのクリックもinteractionとして届くため、対象外のものは応答だけ返す
+				socketMode.Ack(*envelope.Request)
+				callback, ok := envelope.Data.(slack.InteractionCallback)
+				if !ok {
+					slog.ErrorContext(h.ctx, "Failed to cast to InteractionCallback")
+					continue
 				}
+				h.runAsync("interaction", func() { h.handleInteraction(callback) })
 			}
 		}
 	}()
@@ -76,11 +142,34 @@ func (h *Handler) Handle() error {
 	return socketMode.Run()
 }
 
+// 受信方式によらずEvents APIのイベントを処理する
+func (h *Handler) handleEvent(eventPayload slackevents.EventsAPIEvent) {
+	if eventPayload.Type != slackevents.CallbackEvent {
+		return
+	}
+	// Socket Modeではまれに同じイベントが重複配信されるため、二重に処理しないよう無視する
+	switch ev := eventPayload.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		if h.isDuplicateEvent(ev.Type, "", ev.EventTimeStamp, ev.User) {
+			return
+		}
+		h.handleMention(ev)
+	case *slackevents.MessageEvent:
+		if h.isDuplicateEvent(ev.Type, ev.ClientMsgID, ev.EventTimeStamp, ev.User) {
+			return
+		}
+		h.handleMessage(ev)
+	default:
+		slog.DebugContext(h.ctx, "Skipped event", slog.String("type", eventPayload.InnerEvent.Type))
+	}
+}
+
 // エラー内容をポストする関数
-func (h *Handler) postError(channelID, userID, message, ts string) {
+// 原因が一時的な失敗（タイムアウト・5xx・レート制限）の場合のみ再試行ボタンを付ける
+func (h *Handler) postError(channelID, userID, message, ts string, cause error) {
 	blocks := []slack.Block{
 		slack.NewHeaderBlock(
-			slack.NewTextBlockObject("plain_text", "❌ エラー", false, false),
+			slack.NewTextBlockObject("plain_text", h.messages.get("error"), false, false),
 		),
 		slack.NewDividerBlock(),
 		slack.NewSectionBlock(
@@ -88,16 +177,79 @@ func (h *Handler) postError(channelID, userID, message, ts string) {
 			nil, nil,
 		),
 	}
+	if infra.IsTransientError(cause) {
+		if block, ok := h.retryBlock(userID); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	if block, ok := h.traceBlock(); ok {
+		blocks = append(blocks, block)
+	}
 	if _, _, err := h.slackClient.PostMessage(
 		channelID,
 		slack.MsgOptionBlocks(blocks...),
 		slack.MsgOptionTS(ts),
 		slack.MsgOptionLinkNames(false),
 	); err != nil {
-		slog.Error("Failed to post message", slog.Any("err", err))
+		slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
 	}
 }
 
+// Botがチャンネルに参加していないときに招待手順を案内する関数
+// 参加していないチャンネルにはエフェメラルも投稿できないため、問い合わせたユーザーへのDMで案内する
+func (h *Handler) postNotInChannel(channelID, userID string) {
+	dm, _, _, err := h.slackClient.OpenConversation(&slack.OpenConversationParameters{Users: []string{userID}})
+	if err != nil {
+		slog.ErrorContext(h.ctx, "Failed to open DM for invite guidance", slog.String("user", userID), slog.Any("err", err))
+		return
+	}
+	if _, _, err := h.slackClient.PostMessage(
+		dm.ID,
+		slack.MsgOptionText(h.messages.get("error_not_in_channel", channelID, h.botID), false),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post invite guidance", slog.Any("err", err))
+	}
+}
+
+// Botが問い合わせのチャンネルに参加しているかを確認し、参加していなければ招待手順を案内して false を返す
+// DMはBot自身との会話のため確認しない。確認できなかった場合は処理を続ける
+func (h *Handler) ensureBotInChannel(channelID, userID string) bool {
+	if isDirectMessage(channelID) {
+		return true
+	}
+	member, err := h.slack.BotIsMember(channelID)
+	if err != nil {
+		slog.WarnContext(h.ctx, "Failed to check bot membership", slog.String("channel", channelID), slog.Any("err", err))
+		return true
+	}
+	if !member {
+		slog.InfoContext(h.ctx, "Bot is not in channel", slog.String("channel", channelID))
+		h.postNotInChannel(channelID, userID)
+	}
+	return member
+}
+
+// 検索結果の件数表示を組み立てる関数
+func (h *Handler) resultCountText(total, fetched int) string {
+	if total == infra.TotalUnknown {
+		return h.messages.get("result_at_least", fetched, fetched)
+	}
+	if total > fetched {
+		return h.messages.get("result_truncated", total, fetched)
+	}
+	return h.messages.get("result", total)
+}
+
+// 生成したJira検索クエリを通知用に整形する関数
+func formatQueries(queries []string) string {
+	formatted := make([]string, 0, len(queries))
+	for _, q := range queries {
+		formatted = append(formatted, fmt.Sprintf("`%s`", q))
+	}
+	return strings.Join(formatted, "\n")
+}
+
 // メンションを受け取ったときの処理
 func (h *Handler) handleMention(event *slackevents.AppMentionEvent) {
 	channelID := event.Channel
@@ -108,249 +260,531 @@ func (h *Handler) handleMention(event *slackevents.AppMentionEvent) {
 	messageText = strings.TrimSpace(messageText)
 
 	if messageText == "" {
-		h.postError(channelID, userID, "メッセージが空です。入力内容を確認してください。", event.TimeStamp)
+		h.postError(channelID, userID, h.messages.get("error_empty_message"), event.TimeStamp, nil)
+		return
+	}
+
+	if strings.EqualFold(messageText, helpCommand) {
+		h.postHelp(channelID, userID)
+		return
+	}
+
+	if strings.EqualFold(messageText, statusCommand) {
+		h.postStatus(channelID, userID)
+		return
+	}
+
+	h.dispatchInquiry(channelID, userID, messageText, event.TimeStamp)
+}
+
+// メンション無しの通常発言を受け取ったときの処理
+// Botとのダイレクトメッセージと、AUTO_RESPOND_CHANNELS に含まれるチャンネルのユーザー発言のみを対象にする
+func (h *Handler) handleMessage(event *slackevents.MessageEvent) {
+	// Bot投稿・編集や削除などのサブタイプ・スレッド内の返信は無限ループ防止のため除外
+	if event.BotID != "" || event.SubType != "" || event.User == "" || event.User == h.botID {
+		return
+	}
+	if event.ThreadTimeStamp != "" {
+		return
+	}
+	mention := fmt.Sprintf("<@%s>", h.botID)
+	// DMでは app_mention イベントが届かないため、メンションの有無によらずここで処理する
+	if event.ChannelType != "im" {
+		// Botへのメンションを含む発言は app_mention イベントで処理される
+		if strings.Contains(event.Text, mention) {
+			return
+		}
+		if !h.isAutoRespondChannel(event.Channel) {
+			return
+		}
+	}
+
+	messageText := strings.TrimSpace(strings.Replace(event.Text, mention, "", 1))
+	if messageText == "" {
+		return
+	}
+	h.dispatchInquiry(event.Channel, event.User, messageText, event.TimeStamp)
+}
+
+// AUTO_RESPOND_CHANNELS にチャンネルIDまたはチャンネル名が含まれているかを判定する
+func (h *Handler) isAutoRespondChannel(channelID string) bool {
+	return h.channelListed(channelID, h.cfg.Slack.AutoRespondChannels)
+}
+
+// チャンネルIDまたはチャンネル名が channels に含まれているかを判定する
+func (h *Handler) channelListed(channelID string, channels []string) bool {
+	var channelName string
+	for _, c := range channels {
+		if c == channelID {
+			return true
+		}
+		if channelName == "" {
+			channelInfo, err := h.slack.GetChannelInfo(channelID)
+			if err != nil {
+				slog.ErrorContext(h.ctx, "Failed to get channel info", slog.Any("err", err))
+				return false
+			}
+			channelName = channelInfo.Name
+		}
+		if c == channelName {
+			return true
+		}
+	}
+	return false
+}
+
+// 問い合わせ内容を受け付けて検索・要約結果を投稿する処理
+func (h *Handler) handleInquiry(channelID, userID, messageText, ts string) {
+	h = h.withTrace(userID).forUser(userID)
+	defer h.recoverInquiry(channelID, userID, ts)
+	slog.InfoContext(h.ctx, "Inquiry received", slog.String("channel", channelID), slog.String("user", userID))
+
+	if !h.ensureBotInChannel(channelID, userID) {
 		return
 	}
 
 	// 環境変数 SLACK_CHANNEL で指定されたチャンネル以外は応答しない
-	if os.Getenv("SLACK_CHANNEL") != "" {
-		allowedChannel := strings.TrimPrefix(os.Getenv("SLACK_CHANNEL"), "#")
+	if allowedChannel := h.cfg.Slack.Channel; allowedChannel != "" {
 		channelInfo, err := h.slack.GetChannelInfo(channelID)
 		if err != nil {
-			slog.Error("Failed to get channel info", slog.Any("err", err))
+			slog.ErrorContext(h.ctx, "Failed to get channel info", slog.Any("err", err))
 			return
 		}
 
 		if channelInfo.Name != allowedChannel {
-			h.postError(channelID, userID, "このチャンネルでは応答しません。", event.TimeStamp)
+			h.postError(channelID, userID, h.messages.get("error_channel"), ts, nil)
 			return
 		}
-		slog.Info("Allowed channel", slog.String("channel", channelInfo.Name))
+		slog.InfoContext(h.ctx, "Allowed channel", slog.String("channel", channelInfo.Name))
 	}
+	h = h.withRetry(messageText).withQuiet(channelID)
 
-	var lastError error
-	if _, _, err := h.slackClient.PostMessage(
-		channelID,
-		slack.MsgOptionText(":white_check_mark: *お問い合わせを受け付けました！*\nしばらくお待ち下さい。", false),
-		slack.MsgOptionTS(event.TimeStamp),
-		slack.MsgOptionLinkNames(false),
-	); err != nil {
-		slog.Error("Failed to post message", slog.Any("err", err))
+	h.stats.recordInquiry()
+	timing := newTiming()
+
+	// 処理中であることをリアクションで示し、応答まで完了したら付け替える
+	finishReaction := h.startReaction(channelID, ts)
+	succeeded := false
+	defer func() { finishReaction(succeeded) }()
+
+	// 同じ問い合わせの結果がキャッシュにあれば処理をスキップして即返す
+	if cached, ok := h.resultCache.Get(h.resultCacheKey(channelID, userID, messageText)); ok {
+		slog.InfoContext(h.ctx, "Result cache hit", slog.String("query", messageText))
+		if !h.quiet {
+			if _, _, err := h.slackClient.PostMessage(
+				channelID,
+				slack.MsgOptionText(h.messages.get("cached"), false),
+				slack.MsgOptionTS(ts),
+				slack.MsgOptionLinkNames(false),
+			); err != nil {
+				slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+			}
+		}
+		shown, _ := h.splitPage(cached)
+		h.stats.recordHit(shown)
+		h.postResults(channelID, userID, messageText, ts, cached)
+		succeeded = true
 		return
 	}
 
-	// 1. 処理開始の通知
-	{
-		blocks := []slack.Block{
-			slack.NewHeaderBlock(
-				slack.NewTextBlockObject("plain_text", "🚀 Jira問い合わせ開始", false, false),
-			),
-			slack.NewDividerBlock(),
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", "Jira問い合わせを開始します。", false, false),
-				nil, nil,
-			),
-		}
+	var lastError error
+	// QUIET_MODE・QUIET_CHANNELS の場合は受付・開始も通知しない
+	if !h.quiet {
 		if _, _, err := h.slackClient.PostMessage(
 			channelID,
-			slack.MsgOptionBlocks(blocks...),
-			slack.MsgOptionTS(event.TimeStamp),
+			slack.MsgOptionText(h.messages.get("accepted"), false),
+			slack.MsgOptionTS(ts),
 			slack.MsgOptionLinkNames(false),
 		); err != nil {
-			slog.Error("Failed to post message", slog.Any("err", err))
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+			if infra.IsNotInChannelError(err) {
+				h.postNotInChannel(channelID, userID)
+			}
+			return
+		}
+
+		// 1. 処理開始の通知
+		{
+			blocks := []slack.Block{
+				slack.NewHeaderBlock(
+					slack.NewTextBlockObject("plain_text", h.messages.get("start_header"), false, false),
+				),
+				slack.NewDividerBlock(),
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject("mrkdwn", h.messages.get("start"), false, false),
+					nil, nil,
+				),
+			}
+			if _, _, err := h.slackClient.PostMessage(
+				channelID,
+				slack.MsgOptionBlocks(blocks...),
+				slack.MsgOptionTS(ts),
+				slack.MsgOptionLinkNames(false),
+			); err != nil {
+				slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+				return
+			}
+		}
+	}
+
+	// 問い合わせに課題キーが含まれていれば検索クエリを生成せず直接取得する
+	if keys := model.ExtractIssueKeys(messageText, h.cfg.Jira.ProjectKeys()); len(keys) > 0 {
+		if h.handleIssueKeys(channelID, userID, messageText, ts, keys) {
+			succeeded = true
 			return
 		}
 	}
+
 	var issues []infra.Issue
+	var total int
+	var usedQueries []string
+	history := h.conversation.Context(userID)
+	channelHint := h.channelContext(channelID)
 	// 2. Jira検索クエリの生成
+	searchStart := time.Now()
 	err := retry.Retry(5, 1*time.Second, func() error {
-		jiraQuery, err := h.openAI.GenerateJiraQuery(messageText, lastError)
+		jiraQueries, err := h.generateQueries(messageText, history, channelHint, lastError)
+		if err != nil {
+			slog.ErrorContext(h.ctx, "Failed to generate Jira query", slog.Any("err", err))
+			return err
+		}
+
+		// 存在しないフィールドを使ったクエリは検索せずに再生成する
+		jiraQueries, err = h.jira.ValidateQueries(jiraQueries)
 		if err != nil {
-			slog.Error("Failed to generate Jira query", slog.Any("err", err))
+			slog.WarnContext(h.ctx, "Invalid Jira query", slog.Any("err", err))
+			lastError = err
 			return err
 		}
 
 		// 3. 生成したJira検索クエリの通知
-		{
+		if h.verboseSteps {
 			blocks := []slack.Block{
 				slack.NewHeaderBlock(
-					slack.NewTextBlockObject("plain_text", "🔍 Jira検索クエリ", false, false),
+					slack.NewTextBlockObject("plain_text", h.messages.get("query_header"), false, false),
 				),
 				slack.NewDividerBlock(),
 				slack.NewSectionBlock(
-					slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("`%s`", jiraQuery), false, false),
+					slack.NewTextBlockObject("mrkdwn", formatQueries(jiraQueries), false, false),
 					nil, nil,
 				),
 			}
 			if _, _, err := h.slackClient.PostMessage(
 				channelID,
 				slack.MsgOptionBlocks(blocks...),
-				slack.MsgOptionTS(event.TimeStamp),
+				slack.MsgOptionTS(ts),
 				slack.MsgOptionLinkNames(false),
 			); err != nil {
-				slog.Error("Failed to post message", slog.Any("err", err))
+				slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
 				return err
 			}
 		}
 
 		// 4. Jira APIで問い合わせを検索
-		is, err := h.jira.FetchIssues(jiraQuery)
+		jiraQueries = h.withMineFirst(userID, jiraQueries)
+		is, t, err := h.jira.FetchIssuesByQueries(h.ctx, jiraQueries)
 		if err != nil {
-			slog.Error("Failed to fetch Jira issues", slog.Any("err", err))
+			slog.ErrorContext(h.ctx, "Failed to fetch Jira issues", slog.Any("err", err))
 			lastError = err
 			return err
 		}
 		issues = is
+		total = t
+		usedQueries = jiraQueries
 		return nil
 	})
 	if err != nil {
-		slog.Error("Failed to generate Jira query", slog.Any("err", err))
-		h.postError(channelID, userID, "Jira問い合わせの生成に失敗しました。", event.TimeStamp)
+		slog.ErrorContext(h.ctx, "Failed to generate Jira query", slog.Any("err", err))
+		h.postError(channelID, userID, h.messages.get("error_query"), ts, err)
 		return
 	}
 
+	// 0件の場合は条件を緩めて再検索する
+	if len(issues) == 0 {
+		issues, total, usedQueries = h.relaxSearch(channelID, ts, usedQueries)
+	}
+
 	if len(issues) == 0 {
 		if _, _, err := h.slackClient.PostMessage(
 			channelID,
-			slack.MsgOptionText(":white_check_mark: *Jira問い合わせ結果*\n該当する問い合わせが見つかりませんでした。", false),
-			slack.MsgOptionTS(event.TimeStamp),
+			slack.MsgOptionText(h.messages.get("not_found"), false),
+			slack.MsgOptionTS(ts),
 			slack.MsgOptionLinkNames(false),
 		); err != nil {
-			slog.Error("Failed to post message", slog.Any("err", err))
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
 			return
 		}
+		succeeded = true
 		return
 	}
 
 	// 5. Jira問い合わせ結果の通知
-	{
+	if h.verboseSteps {
 		blocks := []slack.Block{
 			slack.NewHeaderBlock(
-				slack.NewTextBlockObject("plain_text", "📊 Jira問い合わせ結果", false, false),
+				slack.NewTextBlockObject("plain_text", h.messages.get("result_header"), false, false),
 			),
 			slack.NewDividerBlock(),
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Jira問い合わせ結果: %d件です。解析を開始します。しばらくお待ち下さい。", len(issues)), false, false),
+				slack.NewTextBlockObject("mrkdwn", h.resultCountText(total, len(issues)), false, false),
 				nil, nil,
 			),
 		}
 		if _, _, err := h.slackClient.PostMessage(
 			channelID,
 			slack.MsgOptionBlocks(blocks...),
-			slack.MsgOptionTS(event.TimeStamp),
+			slack.MsgOptionTS(ts),
 			slack.MsgOptionLinkNames(false),
 		); err != nil {
-			slog.Error("Failed to post message", slog.Any("err", err))
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
 			return
 		}
 	}
 
-	svc := service.NewSelectTopIssueService(h.openAI, h.slack, h.jira, h.slackClient)
+	timing.record(h.messages.get("timing_search"), searchStart)
+
+	svc := h.newSelectService()
 	// 6. Jiraの問い合わせから最も類似している3件を選択
-	selectedIssues, err := svc.SelectTopIssues(messageText, issues, channelID, event.TimeStamp)
+	selectStart := time.Now()
+	selectedIssues, err := svc.SelectTopIssues(h.ctx, messageText, issues, channelID, ts)
+	timing.record(h.messages.get("timing_similarity"), selectStart)
 	if err != nil {
-		slog.Error("Failed to select top issues", slog.Any("err", err))
-		h.postError(channelID, userID, "Jira問い合わせの選択に失敗しました。", event.TimeStamp)
+		slog.ErrorContext(h.ctx, "Failed to select top issues", slog.Any("err", err))
+		h.postError(channelID, userID, h.messages.get("error_select"), ts, err)
 		return
 	}
+	if timedOut := svc.TimedOut(); timedOut != nil {
+		h.postSelectTimeout(channelID, ts, timedOut)
+	}
 
 	if len(selectedIssues) == 0 {
 		if _, _, err := h.slackClient.PostMessage(
 			channelID,
-			slack.MsgOptionText(":white_check_mark: *Jira問い合わせ結果*\n類似度の高い問い合わせが見つかりませんでした。", false),
-			slack.MsgOptionTS(event.TimeStamp),
+			slack.MsgOptionText(h.messages.get("no_similar"), false),
+			slack.MsgOptionTS(ts),
 			slack.MsgOptionLinkNames(false),
 		); err != nil {
-			slog.Error("Failed to post message", slog.Any("err", err))
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
 			return
 		}
+		succeeded = true
 		return
 	}
 
 	// 7. 要約生成の実行
-	// 要約生成開始通知
-	if _, _, err := h.slackClient.PostMessage(
-		channelID,
-		slack.MsgOptionText("🤖 要約生成を開始します...", false),
-		slack.MsgOptionTS(event.TimeStamp),
-		slack.MsgOptionLinkNames(false),
-	); err != nil {
-		slog.Error("Failed to post summary start message", slog.Any("err", err))
+	summaryStart := time.Now()
+	if !h.summarizeAndPost(channelID, userID, messageText, ts, selectedIssues) {
+		return
 	}
+	timing.record(h.messages.get("timing_summary"), summaryStart)
 
-	// error groupを使用して各Issueの要約を並列生成
-	ctx := context.Background()
-	g, _ := errgroup.WithContext(ctx)
+	// 続きのページは要約が未生成のため、連携・記録には最初のページのみを使う
+	shown, _ := h.splitPage(selectedIssues)
+	h.stats.recordHit(shown)
 
-	for i := range selectedIssues {
-		i := i // ループ変数をキャプチャ
-		g.Go(func() error {
-			return h.openAI.GenerateSummaryForIssue(&selectedIssues[i])
+	// 外部システムへの結果連携（非同期）
+	h.webhook.PostAsync(model.WebhookPayload{
+		UserID:    userID,
+		ChannelID: channelID,
+		Query:     messageText,
+		Results:   shown,
+	})
+
+	h.resultCache.Set(h.resultCacheKey(channelID, userID, messageText), selectedIssues)
+	h.conversation.Add(userID, messageText, shown)
+	h.postTiming(channelID, ts, timing)
+	if !h.quiet {
+		h.postFooter(channelID, ts, inquiryFooter{
+			queries: usedQueries,
+			fetched: len(issues),
+			total:   total,
+			shown:   len(shown),
 		})
 	}
+	succeeded = true
+}
 
-	if err := g.Wait(); err != nil {
-		slog.Error("Failed to generate summary", slog.Any("err", err))
-		h.postError(channelID, userID, "Jira問い合わせの要約生成に失敗しました。", event.TimeStamp)
-		return
+// Jira検索クエリを生成する
+// JQL_TEMPLATE が設定されている場合はLLMにキーワードのみ抽出させてテンプレートに差し込む
+func (h *Handler) generateQueries(messageText, history, channelHint string, lastError error) ([]string, error) {
+	if !h.jira.UsesTemplate() {
+		return h.openAI.GenerateJiraQueries(h.ctx, messageText, history, channelHint, lastError)
+	}
+	keywords, err := h.openAI.ExtractKeywords(h.ctx, messageText, history, channelHint, lastError)
+	if err != nil {
+		return nil, err
+	}
+	return []string{h.jira.TemplateQuery(keywords)}, nil
+}
+
+// 各課題の要約を並列生成する関数
+// 失敗した場合はエラーを投稿して false を返す
+func (h *Handler) generateSummaries(channelID, userID, messageText, ts string, results []model.Result) bool {
+	// 要約生成開始通知
+	if h.verboseSteps {
+		if _, _, err := h.slackClient.PostMessage(
+			channelID,
+			slack.MsgOptionText(h.messages.get("summary_start"), false),
+			slack.MsgOptionTS(ts),
+			slack.MsgOptionLinkNames(false),
+		); err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post summary start message", slog.Any("err", err))
+		}
+	}
+
+	// 呼び出し回数を減らすため複数の課題の要約を1リクエストでまとめて生成
+	history := h.conversation.Context(userID)
+	if err := h.openAI.GenerateSummaries(h.ctx, messageText, h.lang, history, results); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to generate summary", slog.Any("err", err))
+		h.postError(channelID, userID, h.messages.get("error_summary"), ts, err)
+		return false
 	}
 
 	// 要約生成完了通知
-	if _, _, err := h.slackClient.PostMessage(
-		channelID,
-		slack.MsgOptionText("✅ 要約生成が完了しました！", false),
-		slack.MsgOptionTS(event.TimeStamp),
-		slack.MsgOptionLinkNames(false),
-	); err != nil {
-		slog.Error("Failed to post summary complete message", slog.Any("err", err))
+	if h.verboseSteps {
+		if _, _, err := h.slackClient.PostMessage(
+			channelID,
+			slack.MsgOptionText(h.messages.get("summary_done"), false),
+			slack.MsgOptionTS(ts),
+			slack.MsgOptionLinkNames(false),
+		); err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post summary complete message", slog.Any("err", err))
+		}
 	}
+	return true
+}
 
-	for _, issue := range selectedIssues {
-		blocks := []slack.Block{
-			// ヘッダー
-			slack.NewHeaderBlock(
-				slack.NewTextBlockObject("plain_text", "📝 Jira Issue", false, false),
-			),
-			slack.NewDividerBlock(),
-			// Jira ID
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*🔖 Jira ID:* %s", issue.ID), false, false),
-				nil, nil,
-			),
-			// JIRA URL
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*🔗 JIRA URL:* %s", issue.URL), false, false),
-				nil, nil,
-			),
-			// Slack URL
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*🔗 Slack URL:* %s", issue.SlackThreadURL), false, false),
-				nil, nil,
-			),
-			// 類似度
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*📊 類似度:* %.2f", issue.Similarity), false, false),
-				nil, nil,
-			),
-			// サマリ見出し
+// 選定した課題を1件ずつ投稿する関数（RESULT_PAGE_SIZE が設定されている場合はその件数ずつ）
+func (h *Handler) postResults(channelID, userID, messageText, ts string, results []model.Result) {
+	h.postResultPage(h.resultThread(channelID, ts, results), pageSession{
+		channelID:   channelID,
+		userID:      userID,
+		messageText: messageText,
+		results:     results,
+	})
+}
+
+// 要約の表示用テキストを返す。項目に分解できなかった場合は生成された本文をそのまま使う
+func (h *Handler) summaryText(issue model.Result) string {
+	if issue.SummaryFields == nil {
+		return issue.GeneratedSummary
+	}
+	return h.summaryFieldsText(*issue.SummaryFields, true)
+}
+
+// 要約の項目を見出し付きで整形する
+// 生成途中は未解決かどうかが確定しないため badge が false の場合はバッジを付けない
+func (h *Handler) summaryFieldsText(f model.SummaryFields, badge bool) string {
+	var parts []string
+	if badge {
+		if f.Unresolved {
+			parts = append(parts, h.messages.get("summary_badge_unresolved"))
+		} else {
+			parts = append(parts, h.messages.get("summary_badge_resolved"))
+		}
+	}
+	if f.Overview != "" {
+		parts = append(parts, h.messages.get("summary_overview", f.Overview))
+	}
+	if f.Resolution != "" {
+		parts = append(parts, h.messages.get("summary_resolution", f.Resolution))
+	}
+	if f.Assignees != "" {
+		parts = append(parts, h.messages.get("summary_assignees", f.Assignees))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// URLを開くボタンのaccessoryを返す。URLが無い場合はボタンを出さない
+func (h *Handler) linkButton(actionID, label, url string) *slack.Accessory {
+	if url == "" {
+		return nil
+	}
+	button := slack.NewButtonBlockElement(actionID, "", slack.NewTextBlockObject("plain_text", label, false, false))
+	button.URL = url
+	return slack.NewAccessory(button)
+}
+
+// 1件の課題の結果を表示するブロックを組み立てる
+func (h *Handler) issueBlocks(issue model.Result) []slack.Block {
+	blocks := []slack.Block{
+		// ヘッダー
+		slack.NewHeaderBlock(
+			slack.NewTextBlockObject("plain_text", h.issueHeader(issue.Key, issue.Status), false, false),
+		),
+		slack.NewDividerBlock(),
+		// Jira ID
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", h.messages.get("issue_id", issue.ID), false, false),
+			nil, nil,
+		),
+		// 担当者・優先度
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", h.messages.get("issue_assignee", issue.Assignee, issue.Priority), false, false),
+			nil, nil,
+		),
+		// JIRA URL
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", h.messages.get("issue_url", issue.URL), false, false),
+			nil, h.linkButton("open_jira", h.messages.get("button_open_jira"), issue.URL),
+		),
+		// Slack URL
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", h.messages.get("issue_slack_url", issue.SlackThreadURL), false, false),
+			nil, h.linkButton("open_slack_thread", h.messages.get("button_open_slack"), issue.SlackThreadURL),
+		),
+		// 類似度（課題キーで直接指定された課題は類似度を計算しない）
+		similarityBlock(h.messages, issue),
+		// サマリ見出し
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", h.messages.get("issue_summary"), false, false),
+			nil, nil,
+		),
+	}
+	// サマリの本文（ボックス表示）。sectionブロックの文字数上限を超える場合は複数ブロックに分割する
+	for _, text := range splitText(h.summaryText(issue), maxSectionTextLength-len(">>> ")) {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(">>> %s", text), false, false),
+			nil, nil,
+		))
+	}
+	// 課題本文・コメントから抽出した参考リンク
+	if len(issue.References) > 0 {
+		var refs []string
+		for _, r := range issue.References {
+			refs = append(refs, h.messages.get("issue_reference_item", r.URL, r.Label))
+		}
+		blocks = append(blocks,
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", "*📝 サマリ:*", false, false),
+				slack.NewTextBlockObject("mrkdwn", truncateRunes(h.messages.get("issue_references", strings.Join(refs, "\n")), maxSectionTextLength), false, false),
 				nil, nil,
 			),
-			// サマリの本文（ボックス表示）
+		)
+	}
+	// 同じSlackスレッドに紐づく関連課題
+	if len(issue.RelatedIssues) > 0 {
+		var related []string
+		for _, r := range issue.RelatedIssues {
+			related = append(related, h.messages.get("issue_related_item", r.URL, r.Summary, r.Similarity))
+		}
+		blocks = append(blocks,
 			slack.NewSectionBlock(
-				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(">>> %s", issue.GeneratedSummary), false, false),
+				slack.NewTextBlockObject("mrkdwn", h.messages.get("issue_related", strings.Join(related, "\n")), false, false),
 				nil, nil,
 			),
-			slack.NewDividerBlock(),
-		}
-		if _, _, err := h.slackClient.PostMessage(
-			channelID,
-			slack.MsgOptionBlocks(blocks...),
-			slack.MsgOptionTS(event.TimeStamp),
-			slack.MsgOptionLinkNames(false),
-		); err != nil {
-			slog.Error("Failed to post message", slog.Any("err", err))
-		}
+		)
+	}
+	blocks = append(blocks, slack.NewDividerBlock())
+	return blocks
+}
+
+// 結果キャッシュのキーを組み立てる
+// 結果にはユーザートークンで検索したSlackスレッドやチャンネルの情報が含まれるため、チャンネルをまたいで共有しない
+// SEARCH_MINE_FIRST・CONVERSATION_MEMORY が有効な場合は結果がユーザーごとに異なるため、ユーザーも含める
+func (h *Handler) resultCacheKey(channelID, userID, messageText string) string {
+	parts := []string{h.lang, channelID}
+	if h.cfg.Jira.SearchMineFirst || h.cfg.Handler.ConversationMemory {
+		parts = append(parts, userID)
 	}
+	return strings.Join(append(parts, messageText), "\x00")
 }
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// ヘルプ表示のキーワード
+// 通常の問い合わせと区別するため、メンションを除いた本文全体が一致した場合のみ扱う
+const helpCommand = "help"
+
+// 使い方をエフェメラルで返す
+func (h *Handler) postHelp(channelID, userID string) {
+	channel := h.messages.get("help_any_channel")
+	if h.cfg.Slack.Channel != "" {
+		channel = "#" + h.cfg.Slack.Channel
+	}
+	searchChannels := h.messages.get("footer_all_channels")
+	if len(h.cfg.Slack.SearchAllowChannels) > 0 {
+		searchChannels = strings.Join(h.cfg.Slack.SearchAllowChannels, ", ")
+	}
+	text := strings.Join([]string{
+		h.messages.get("help_header"),
+		h.messages.get("help_usage"),
+		h.messages.get("help_options", helpCommand, statusCommand),
+		h.messages.get("help_channels", channel, searchChannels),
+		h.messages.get("help_constraints"),
+	}, "\n\n")
+	if _, err := h.slackClient.PostEphemeral(
+		channelID,
+		userID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post help", slog.Any("err", err))
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// HTTP Events APIでイベントを受信する
+// /slack/events でURL verificationとイベントを、/slack/interactions でボタンのクリックを受け付ける
+func (h *Handler) serveHTTP() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slack/events", h.handleEventsRequest)
+	mux.HandleFunc("/slack/interactions", h.handleInteractionsRequest)
+
+	server := &http.Server{
+		Addr:              h.cfg.Slack.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	slog.InfoContext(h.ctx, "Listening for Slack events", slog.String("addr", h.cfg.Slack.HTTPAddr))
+	return server.ListenAndServe()
+}
+
+// リクエストの署名を検証し、本文を返す
+// 検証に失敗した場合はエラーレスポンスを書き込んで false を返す
+func (h *Handler) verifyRequest(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.ErrorContext(h.ctx, "Failed to read request body", slog.Any("err", err))
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+
+	verifier, err := slack.NewSecretsVerifier(r.Header, h.cfg.Slack.SigningSecret)
+	if err != nil {
+		slog.WarnContext(h.ctx, "Invalid Slack signature headers", slog.Any("err", err))
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	if _, err := verifier.Write(body); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if err := verifier.Ensure(); err != nil {
+		slog.WarnContext(h.ctx, "Slack signature verification failed", slog.Any("err", err))
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
+
+func (h *Handler) handleEventsRequest(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.verifyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// 署名検証済みのためトークンの検証は行わない
+	eventPayload, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		slog.ErrorContext(h.ctx, "Failed to parse Slack event", slog.Any("err", err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if eventPayload.Type == slackevents.URLVerification {
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(challenge.Challenge))
+		return
+	}
+
+	// 3秒以内に応答しないと再送されるため、先に応答してから処理する
+	w.WriteHeader(http.StatusOK)
+
+	// 応答済みのイベントの再送は二重に処理しない
+	if retryNum := r.Header.Get("X-Slack-Retry-Num"); retryNum != "" {
+		slog.InfoContext(h.ctx, "Skip retried Slack event",
+			slog.String("retry_num", retryNum),
+			slog.String("reason", r.Header.Get("X-Slack-Retry-Reason")))
+		return
+	}
+	h.runAsync("event", func() { h.handleEvent(eventPayload) })
+}
+
+// リンクボタンのクリックもinteractionとして届くため、対象外のものは応答だけ返す
+func (h *Handler) handleInteractionsRequest(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.verifyRequest(w, r)
+	if !ok {
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to parse Slack interaction", slog.Any("err", err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	h.runAsync("interaction", func() { h.handleInteraction(callback) })
+}
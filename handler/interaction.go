@@ -0,0 +1,21 @@
+package handler
+
+import "github.com/slack-go/slack"
+
+// ボタンのクリックなどのinteractionを処理する
+func (h *Handler) handleInteraction(callback slack.InteractionCallback) {
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+	h = h.withTrace(callback.User.ID).forUser(callback.User.ID)
+	for _, action := range callback.ActionCallback.BlockActions {
+		switch action.ActionID {
+		case shareActionID:
+			h.shareResult(callback, action.Value)
+		case retryActionID:
+			h.retryInquiry(callback, action.BlockID, action.Value)
+		case nextPageActionID:
+			h.postNextPage(callback, action.Value)
+		}
+	}
+}
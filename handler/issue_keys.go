@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/slack-go/slack"
+)
+
+// 課題キーで指定された課題を直接取得して要約・比較を投稿する
+// 課題が取得できなかった場合は false を返し、呼び出し元で通常の検索に進む
+func (h *Handler) handleIssueKeys(channelID, userID, messageText, ts string, keys []string) bool {
+	issues, err := h.jira.FetchIssuesByKeys(h.ctx, keys)
+	if err != nil {
+		slog.WarnContext(h.ctx, "Failed to fetch issues by keys, fallback to query generation", slog.Any("keys", keys), slog.Any("err", err))
+		return false
+	}
+	if len(issues) == 0 {
+		slog.InfoContext(h.ctx, "No issues found by keys, fallback to query generation", slog.Any("keys", keys))
+		return false
+	}
+	slog.InfoContext(h.ctx, "Fetched issues by keys", slog.Any("keys", keys), slog.Int("count", len(issues)))
+
+	svc := h.newSelectService()
+	results := svc.ResolveIssues(h.ctx, issues, channelID)
+
+	if !h.summarizeAndPost(channelID, userID, messageText, ts, results) {
+		return true
+	}
+
+	h.stats.recordHit(results)
+	h.conversation.Add(userID, messageText, results)
+	h.webhook.PostAsync(model.WebhookPayload{
+		UserID:    userID,
+		ChannelID: channelID,
+		Query:     messageText,
+		Results:   results,
+	})
+
+	// 複数の課題が指定された場合は違いを比較して投稿する
+	if len(results) > 1 {
+		comparison, err := h.openAI.CompareIssues(h.ctx, messageText, h.lang, results)
+		if err != nil {
+			slog.ErrorContext(h.ctx, "Failed to compare issues", slog.Any("err", err))
+			h.postError(channelID, userID, h.messages.get("error_compare"), ts, err)
+			return true
+		}
+		blocks := []slack.Block{
+			slack.NewHeaderBlock(
+				slack.NewTextBlockObject("plain_text", h.messages.get("compare_header"), false, false),
+			),
+			slack.NewDividerBlock(),
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(">>> %s", comparison), false, false),
+				nil, nil,
+			),
+		}
+		if _, _, err := h.slackClient.PostMessage(
+			channelID,
+			slack.MsgOptionBlocks(blocks...),
+			slack.MsgOptionTS(ts),
+			slack.MsgOptionLinkNames(false),
+		); err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+		}
+	}
+	return true
+}
+
+// 類似度の表示ブロックを返す。課題キーで直接指定された課題はその旨を表示する
+func similarityBlock(m messages, issue model.Result) slack.Block {
+	text := m.get("issue_similarity", issue.Similarity)
+	switch {
+	case issue.Referenced:
+		text = m.get("issue_referenced")
+	case issue.LowSimilarity:
+		text += "\n" + m.get("issue_low_similarity")
+	}
+	return slack.NewSectionBlock(
+		slack.NewTextBlockObject("mrkdwn", text, false, false),
+		nil, nil,
+	)
+}
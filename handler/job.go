@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"log/slog"
+	"runtime/debug"
+)
+
+// イベントの処理を非同期に実行する
+// 受信ループやHTTPの応答を待たせないよう、Ack後の処理はすべてこの中で行う
+// panicした場合もプロセスを落とさずにログへ記録する
+func (h *Handler) runAsync(name string, fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.ErrorContext(h.ctx, "Recovered from panic in async job",
+					slog.String("job", name),
+					slog.Any("panic", r),
+					slog.String("stack", string(debug.Stack())))
+			}
+		}()
+		fn()
+	}()
+}
+
+// 問い合わせの処理中にpanicした場合にエラーを投稿する
+// handleInquiry の先頭で defer して使う
+func (h *Handler) recoverInquiry(channelID, userID, ts string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	slog.ErrorContext(h.ctx, "Recovered from panic while handling inquiry",
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())))
+	h.postError(channelID, userID, h.messages.get("error_internal"), ts, nil)
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/pyama86/jipcy/config"
+	"github.com/pyama86/jipcy/domain/infra"
+)
+
+// SUMMARY_LANGUAGE で指定された既定の応答言語を返す。未指定の場合は日本語とする
+func defaultLanguage(cfg *config.Config) string {
+	if lang := cfg.OpenAI.SummaryLanguage; infra.IsSupportedLanguage(lang) {
+		return lang
+	}
+	return "ja"
+}
+
+// Slackのlocale（ja-JP、en-US など）から応答言語を返す
+// 対応していない言語の場合は空を返す
+func localeLanguage(locale string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	if !infra.IsSupportedLanguage(lang) {
+		return ""
+	}
+	return lang
+}
+
+// 問い合わせしたユーザーのlocaleに合わせた応答言語のHandlerを返す
+// localeが取得できない場合は既定の言語のまま返す
+func (h *Handler) forUser(userID string) *Handler {
+	user, err := h.slack.GetUserByID(userID)
+	if err != nil {
+		slog.DebugContext(h.ctx, "Failed to get user locale, fallback to default language", slog.String("user", userID), slog.Any("err", err))
+		return h
+	}
+	lang := localeLanguage(user.Locale)
+	if lang == "" {
+		return h
+	}
+	c := *h
+	c.lang = lang
+	c.messages = h.messageSets[lang]
+	return &c
+}
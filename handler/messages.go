@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 応答メッセージの既定値
+// MESSAGES_FILE で指定したファイルに同じキーを定義すると上書きできる
+var defaultMessages = map[string]string{
+	"accepted":                    ":white_check_mark: *お問い合わせを受け付けました！*\n処理を開始しました。結果の投稿まで数十秒かかります。",
+	"cached":                      "♻️ 同じ問い合わせの結果をキャッシュから表示します。",
+	"start_header":                "🚀 Jira問い合わせ開始",
+	"start":                       "Jira問い合わせを開始します。",
+	"query_header":                "🔍 Jira検索クエリ",
+	"result_header":               "📊 Jira問い合わせ結果",
+	"result":                      "Jira問い合わせ結果: %d件です。解析を開始します。しばらくお待ち下さい。",
+	"result_truncated":            "Jira問い合わせ結果: %d件です(上位%d件を解析します)。解析を開始します。しばらくお待ち下さい。",
+	"result_at_least":             "Jira問い合わせ結果: %d件以上です(取得した%d件を解析します)。解析を開始します。しばらくお待ち下さい。",
+	"not_found":                   ":white_check_mark: *Jira問い合わせ結果*\n該当する問い合わせが見つかりませんでした。",
+	"relaxed_keywords":            ":mag: 該当する問い合わせが見つからなかったため、キーワードを減らして再検索しました。",
+	"relaxed_project_only":        ":mag: 該当する問い合わせが見つからなかったため、キーワードを外してプロジェクト内の最近の課題から探しました。",
+	"no_similar":                  ":white_check_mark: *Jira問い合わせ結果*\n類似度の高い問い合わせが見つかりませんでした。",
+	"select_timed_out":            ":hourglass: 処理時間の上限(%s)に達したため、%d件中%d件の評価結果から表示しています。",
+	"summary_start":               "🤖 要約生成を開始します...",
+	"summary_done":                "✅ 要約生成が完了しました！",
+	"summary_streaming":           "_要約を生成中です..._",
+	"issue_header":                "📝 Jira Issue",
+	"issue_id":                    "*🔖 Jira ID:* %s",
+	"issue_assignee":              "*👤 担当者:* %s　*⚡ 優先度:* %s",
+	"issue_url":                   "*🔗 JIRA URL:* %s",
+	"issue_slack_url":             "*🔗 Slack URL:* %s",
+	"button_open_jira":            "Jiraで開く",
+	"button_open_slack":           "Slackスレッドを開く",
+	"button_share":                "チャンネルに共有",
+	"button_retry":                "再試行",
+	"retry_not_owner":             "再試行できるのは問い合わせたユーザーのみです。",
+	"button_next_page":            "次の%d件を表示",
+	"page_remaining":              "未表示の結果が%d件あります。",
+	"page_expired":                "表示期限が切れました。もう一度問い合わせてください。",
+	"share_footer":                "📤 <@%s> さんのDMでの問い合わせ結果を転記しました",
+	"share_done":                  "<#%s> に共有しました。",
+	"error_share":                 "<#%s> への共有に失敗しました。Botがチャンネルに参加しているか確認してください。",
+	"issue_similarity":            "*📊 類似度:* %.2f",
+	"issue_summary":               "*📝 サマリ:*",
+	"summary_overview":            "*概要*\n%s",
+	"summary_resolution":          "*解決結果*\n%s",
+	"summary_assignees":           "*担当者・チーム*\n%s",
+	"summary_badge_unresolved":    "🚧 *未解決*",
+	"summary_badge_resolved":      "✅ *解決済み*",
+	"issue_referenced":            "*📌 問い合わせで指定された課題*",
+	"issue_low_similarity":        "_⚠️ 参考(低類似度): しきい値以上の課題が見つからなかったため表示しています_",
+	"compare_header":              "🆚 課題の比較",
+	"timing":                      "⏱ 処理時間: 総%.1f秒 (%s)",
+	"timing_step":                 "%s%.1f秒",
+	"timing_search":               "Jira検索",
+	"timing_similarity":           "類似度",
+	"timing_summary":              "要約",
+	"footer":                      "🔎 JQL: %s | 検索チャンネル: %s | 取得 %d件 (全%s) → 表示 %d件 | しきい値 %.2f",
+	"footer_all_channels":         "全チャンネル",
+	"total_count":                 "%d件",
+	"total_at_least":              "%d件以上",
+	"issue_related":               "*🧵 同じスレッドの関連課題:*\n%s",
+	"issue_related_item":          "• <%s|%s> (類似度: %.2f)",
+	"issue_references":            "*📎 参考リンク:*\n%s",
+	"issue_reference_item":        "• <%s|%s>",
+	"collapsed_header":            ":bookmark_tabs: *類似課題 %d件*（詳細はスレッドを参照）",
+	"collapsed_item":              "• <%s|%s %s> (類似度: %.2f)",
+	"collapsed_remaining":         "…あと%d件（スレッドの「次の%d件を表示」で表示できます）",
+	"collapsed_link":              ":point_right: 結果を<%s|こちらのスレッド>にまとめました。",
+	"error":                       "❌ エラー",
+	"error_trace_id":              "トレースID: `%s`",
+	"error_empty_message":         "メッセージが空です。入力内容を確認してください。",
+	"error_channel":               "このチャンネルでは応答しません。",
+	"error_not_in_channel":        "⚠️ Botが <#%[1]s> に参加していないため応答できません。Botをチャンネルに招待してください。\n*招待手順*\n1. <#%[1]s> で `/invite <@%[2]s>` を実行する\n2. または、チャンネル名をクリック →「インテグレーション」→「アプリを追加する」からBotを追加する",
+	"error_query":                 "Jira問い合わせの生成に失敗しました。",
+	"error_select":                "Jira問い合わせの選択に失敗しました。",
+	"error_summary":               "Jira問い合わせの要約生成に失敗しました。",
+	"error_internal":              "内部エラーが発生したため処理を中断しました。",
+	"error_compare":               "課題の比較に失敗しました。",
+	"stats_header":                "📈 本日の利用統計",
+	"stats_summary":               "*%s の利用統計*\n• 問い合わせ件数: %d件\n• ヒット件数: %d件 (ヒット率: %.1f%%)\n• 平均類似度: %.2f",
+	"select_narrowed":             "✂️ 処理対象を上位%d件に絞り込みました（%d件を除外）",
+	"select_issue_done":           "✅ 処理完了: `%s` - %s (類似度: %.2f)",
+	"select_issue_excluded":       "⚪ 処理完了: `%s` - %s (類似度: %.2f - 除外)",
+	"select_issue_error":          "❌ 処理エラー: `%s` - %s (エラー: %v)",
+	"select_elapsed":              " [+%.1f秒]",
+	"select_failed_notifications": "⚠️ 送信できなかった通知が%d件あります\n%s",
+	"select_distribution":         "📈 類似度の分布 (%d件): 平均 %.2f / 中央値 %.2f / 最大 %.2f / 最小 %.2f / しきい値(%.2f)以上 %d件",
+	"status_header":               "⚙️ *現在の設定*",
+	"status_unset":                "(未設定)",
+	"status_fallback":             " (フォールバック: %s)",
+	"status_provider":             "*LLMプロバイダ:* %s",
+	"status_model":                "*モデル:* %s",
+	"status_condense_model":       "*圧縮用モデル:* %s",
+	"status_threshold":            "*類似度しきい値:* %.2f",
+	"status_max_search":           "*Jira検索件数の上限:* %d",
+	"status_max_results":          "*表示件数の上限:* %d",
+	"status_concurrency":          "*並列度:* %d",
+	"status_jira_endpoint":        "*Jiraエンドポイント:* %s",
+	"status_project_key":          "*プロジェクトキー:* %s",
+	"status_jira_auth":            "*Jira認証方式:* %s",
+	"status_channel":              "*応答チャンネル:* %s",
+	"status_rate_limit":           "*Jiraレートリミット:* 残り %d / %d",
+	"status_rate_limit_reset":     " (回復: %s)",
+	"status_updated_at":           " _%s 時点_",
+	"error_not_admin":             "このコマンドは管理者のみ実行できます。",
+	"help_header":                 "❓ *jipcy の使い方*",
+	"help_usage":                  "*問い合わせ方法*\nBotにメンションして問い合わせ内容を書くと、過去のJira課題から類似するものを探して要約を返します。\n例: `@bot ログインできないという問い合わせ`\n問い合わせに課題キー(例: `PROJ-123`)を含めると、その課題も結果に含めます。",
+	"help_options":                "*コマンド*\n• `@bot %s`: この使い方を表示します\n• `@bot %s`: 現在の設定を表示します(管理者のみ)",
+	"help_channels":               "*対象チャンネル*\n• 応答するチャンネル: %s\n• Slackスレッドの検索対象: %s",
+	"help_constraints":            "*制約*\n• 結果の投稿まで数十秒かかります。\n• 表示されるのは類似度の高い課題の上位のみです。\n• 要約はLLMによる自動生成のため、必ずJiraの原文も確認してください。",
+	"help_any_channel":            "全チャンネル",
+}
+
+// 英語の応答メッセージの既定値
+var englishMessages = map[string]string{
+	"accepted":                    ":white_check_mark: *Your inquiry has been received!*\nProcessing has started. Results will be posted in a few dozen seconds.",
+	"cached":                      "♻️ Showing cached results for the same inquiry.",
+	"start_header":                "🚀 Jira search started",
+	"start":                       "Starting the Jira search.",
+	"query_header":                "🔍 Jira search queries",
+	"result_header":               "📊 Jira search results",
+	"result":                      "Found %d issues in Jira. Starting the analysis, please wait a moment.",
+	"result_truncated":            "Found %d issues in Jira (analyzing the top %d). Starting the analysis, please wait a moment.",
+	"result_at_least":             "Found %d or more issues in Jira (analyzing the %d fetched). Starting the analysis, please wait a moment.",
+	"not_found":                   ":white_check_mark: *Jira search results*\nNo matching issues were found.",
+	"relaxed_keywords":            ":mag: No matching issues were found, so the search was retried with fewer keywords.",
+	"relaxed_project_only":        ":mag: No matching issues were found, so the search was retried against recent issues in the project without keywords.",
+	"no_similar":                  ":white_check_mark: *Jira search results*\nNo similar issues were found.",
+	"select_timed_out":            ":hourglass: The time limit (%s) was reached, so results are based on %[3]d of %[2]d issues evaluated.",
+	"summary_start":               "🤖 Generating summaries...",
+	"summary_done":                "✅ Summaries have been generated!",
+	"summary_streaming":           "_Generating the summary..._",
+	"issue_header":                "📝 Jira Issue",
+	"issue_id":                    "*🔖 Jira ID:* %s",
+	"issue_assignee":              "*👤 Assignee:* %s　*⚡ Priority:* %s",
+	"issue_url":                   "*🔗 JIRA URL:* %s",
+	"issue_slack_url":             "*🔗 Slack URL:* %s",
+	"button_open_jira":            "Open in Jira",
+	"button_open_slack":           "Open Slack thread",
+	"button_share":                "Share to channel",
+	"button_retry":                "Retry",
+	"retry_not_owner":             "Only the user who asked can retry this inquiry.",
+	"button_next_page":            "Show next %d",
+	"page_remaining":              "%d more results are available.",
+	"page_expired":                "These results have expired. Please ask again.",
+	"share_footer":                "📤 Shared from a direct message inquiry by <@%s>",
+	"share_done":                  "Shared to <#%s>.",
+	"error_share":                 "Failed to share to <#%s>. Please make sure the bot is a member of the channel.",
+	"issue_similarity":            "*📊 Similarity:* %.2f",
+	"issue_summary":               "*📝 Summary:*",
+	"summary_overview":            "*Overview*\n%s",
+	"summary_resolution":          "*Resolution*\n%s",
+	"summary_assignees":           "*Assignees / teams*\n%s",
+	"summary_badge_unresolved":    "🚧 *Unresolved*",
+	"summary_badge_resolved":      "✅ *Resolved*",
+	"issue_referenced":            "*📌 Issue specified in the inquiry*",
+	"issue_low_similarity":        "_⚠️ For reference (low similarity): no issues above the threshold were found_",
+	"compare_header":              "🆚 Issue comparison",
+	"timing":                      "⏱ Processing time: %.1fs total (%s)",
+	"timing_step":                 "%s %.1fs",
+	"timing_search":               "Jira search",
+	"timing_similarity":           "similarity",
+	"timing_summary":              "summary",
+	"footer":                      "🔎 JQL: %s | Channels: %s | Fetched %d (of %s) → Shown %d | Threshold %.2f",
+	"footer_all_channels":         "all channels",
+	"total_count":                 "%d",
+	"total_at_least":              "%d+",
+	"issue_related":               "*🧵 Related issues in the same thread:*\n%s",
+	"issue_related_item":          "• <%s|%s> (similarity: %.2f)",
+	"issue_references":            "*📎 References:*\n%s",
+	"issue_reference_item":        "• <%s|%s>",
+	"collapsed_header":            ":bookmark_tabs: *%d similar issues* (see the thread for details)",
+	"collapsed_item":              "• <%s|%s %s> (similarity: %.2f)",
+	"collapsed_remaining":         "...and %d more (use \"Show next %d\" in the thread)",
+	"collapsed_link":              ":point_right: Results are collected in <%s|this thread>.",
+	"error":                       "❌ Error",
+	"error_trace_id":              "Trace ID: `%s`",
+	"error_empty_message":         "The message is empty. Please check your input.",
+	"error_channel":               "This bot does not respond in this channel.",
+	"error_not_in_channel":        "⚠️ The bot cannot respond because it is not a member of <#%[1]s>. Please invite the bot to the channel.\n*How to invite*\n1. Run `/invite <@%[2]s>` in <#%[1]s>\n2. Or click the channel name → \"Integrations\" → \"Add apps\" and add the bot",
+	"error_query":                 "Failed to generate the Jira search queries.",
+	"error_select":                "Failed to select Jira issues.",
+	"error_summary":               "Failed to generate summaries of Jira issues.",
+	"error_internal":              "Processing was aborted due to an internal error.",
+	"error_compare":               "Failed to compare the issues.",
+	"stats_header":                "📈 Today's usage statistics",
+	"stats_summary":               "*Usage statistics for %s*\n• Inquiries: %d\n• Hits: %d (hit rate: %.1f%%)\n• Average similarity: %.2f",
+	"select_narrowed":             "✂️ Narrowed down to the top %d issues (%d excluded)",
+	"select_issue_done":           "✅ Done: `%s` - %s (similarity: %.2f)",
+	"select_issue_excluded":       "⚪ Done: `%s` - %s (similarity: %.2f - excluded)",
+	"select_issue_error":          "❌ Failed: `%s` - %s (error: %v)",
+	"select_elapsed":              " [+%.1fs]",
+	"select_failed_notifications": "⚠️ %d notifications could not be sent\n%s",
+	"select_distribution":         "📈 Similarity distribution (%d issues): mean %.2f / median %.2f / max %.2f / min %.2f / at or above threshold (%.2f) %d",
+	"status_header":               "⚙️ *Current settings*",
+	"status_unset":                "(not set)",
+	"status_fallback":             " (fallback: %s)",
+	"status_provider":             "*LLM provider:* %s",
+	"status_model":                "*Model:* %s",
+	"status_condense_model":       "*Condense model:* %s",
+	"status_threshold":            "*Similarity threshold:* %.2f",
+	"status_max_search":           "*Max Jira search results:* %d",
+	"status_max_results":          "*Max results shown:* %d",
+	"status_concurrency":          "*Concurrency:* %d",
+	"status_jira_endpoint":        "*Jira endpoint:* %s",
+	"status_project_key":          "*Project key:* %s",
+	"status_jira_auth":            "*Jira auth type:* %s",
+	"status_channel":              "*Response channel:* %s",
+	"status_rate_limit":           "*Jira rate limit:* %d / %d remaining",
+	"status_rate_limit_reset":     " (resets at %s)",
+	"status_updated_at":           " _as of %s_",
+	"error_not_admin":             "Only administrators can use this command.",
+	"help_header":                 "❓ *How to use jipcy*",
+	"help_usage":                  "*How to ask*\nMention the bot with your question and it will find similar past Jira issues and summarize them.\nExample: `@bot users cannot log in`\nInclude an issue key (e.g. `PROJ-123`) to add that issue to the results.",
+	"help_options":                "*Commands*\n• `@bot %s`: show this help\n• `@bot %s`: show the current settings (administrators only)",
+	"help_channels":               "*Channels*\n• Responds in: %s\n• Searches Slack threads in: %s",
+	"help_constraints":            "*Notes*\n• Results take a few dozen seconds to be posted.\n• Only the most similar issues are shown.\n• Summaries are generated by an LLM, so always check the original Jira issue.",
+	"help_any_channel":            "all channels",
+}
+
+// 言語ごとの応答メッセージの既定値
+var defaultMessagesByLanguage = map[string]map[string]string{
+	"ja": defaultMessages,
+	"en": englishMessages,
+}
+
+type messages map[string]string
+
+// MESSAGES_FILE (YAML/JSON) からメッセージ定義を読み込む
+// ファイルが無い・読み込めない場合は既定の文言を使用する
+// 言語ごとの既定値を元にし、MESSAGES_FILE の定義は言語によらず優先する
+func loadMessages(lang, path string) messages {
+	base, ok := defaultMessagesByLanguage[lang]
+	if !ok {
+		base = defaultMessages
+	}
+	m := make(messages, len(base))
+	for k, v := range base {
+		m[k] = v
+	}
+
+	if path == "" {
+		return m
+	}
+
+	overrides, err := readMessagesFile(path)
+	if err != nil {
+		slog.Error("Failed to load MESSAGES_FILE, fallback to default messages", slog.String("path", path), slog.Any("err", err))
+		return m
+	}
+	for k, v := range overrides {
+		m[k] = v
+	}
+	return m
+}
+
+// 対応している全言語のメッセージ定義を読み込む
+func loadMessageSets(path string) map[string]messages {
+	sets := make(map[string]messages, len(defaultMessagesByLanguage))
+	for lang := range defaultMessagesByLanguage {
+		sets[lang] = loadMessages(lang, path)
+	}
+	return sets
+}
+
+func readMessagesFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages file: %w", err)
+	}
+	// YAMLはJSONの上位互換なので両方の形式をそのまま読み込める
+	var overrides map[string]string
+	if err := yaml.Unmarshal(b, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse messages file: %w", err)
+	}
+	return overrides, nil
+}
+
+// キーに対応するメッセージを返す。引数があればフォーマットする
+func (m messages) get(key string, args ...any) string {
+	msg, ok := m[key]
+	if !ok {
+		slog.Warn("Unknown message key", slog.String("key", key))
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"log/slog"
+
+	"github.com/pyama86/jipcy/domain/infra"
+)
+
+// SEARCH_MINE_FIRST が有効な場合、問い合わせ者が担当者または報告者の課題に絞ったクエリを先頭に加える
+// 検索結果は先頭のクエリから順にまとめられるため、問い合わせ者の課題が優先される
+// SlackのメールアドレスからJiraのユーザーを引けない場合は元のクエリのまま返す
+func (h *Handler) withMineFirst(userID string, queries []string) []string {
+	if !h.cfg.Jira.SearchMineFirst {
+		return queries
+	}
+	user, err := h.slack.GetUserByID(userID)
+	if err != nil {
+		slog.WarnContext(h.ctx, "Failed to get Slack user, fallback to normal search", slog.String("user", userID), slog.Any("err", err))
+		return queries
+	}
+	if user.Profile.Email == "" {
+		slog.WarnContext(h.ctx, "Slack user has no email, fallback to normal search", slog.String("user", userID))
+		return queries
+	}
+	accountID, err := h.jira.UserAccountID(user.Profile.Email)
+	if err != nil {
+		slog.WarnContext(h.ctx, "Failed to find Jira user by email, fallback to normal search", slog.String("user", userID), slog.Any("err", err))
+		return queries
+	}
+
+	mine := make([]string, 0, len(queries)*2)
+	for _, q := range queries {
+		mine = append(mine, infra.WithInvolvedUser(q, accountID))
+	}
+	return append(mine, queries...)
+}
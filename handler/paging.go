@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"time"
+
+	ttlcache "github.com/jellydator/ttlcache/v3"
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// 「次のN件を表示」ボタンのaction_id
+	nextPageActionID = "next_page"
+	// 未表示の結果を保持する期間
+	pageSessionTTL = 30 * time.Minute
+)
+
+// 未表示の結果をボタンの押下まで保持する
+// 続きのページの要約は表示するときに生成するため、問い合わせ内容も保持する
+type pageSession struct {
+	channelID   string
+	userID      string
+	messageText string
+	results     []model.Result
+}
+
+func newPageSessions() *ttlcache.Cache[string, pageSession] {
+	cache := ttlcache.New(ttlcache.WithTTL[string, pageSession](pageSessionTTL))
+	go cache.Start()
+	return cache
+}
+
+func newPageSessionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		slog.Warn("Failed to generate page session id", slog.Any("err", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// 結果を最初のページと残りに分ける（RESULT_PAGE_SIZE が 0 の場合は全件を最初のページとする）
+func (h *Handler) splitPage(results []model.Result) (page, rest []model.Result) {
+	if size := h.cfg.Handler.ResultPageSize; size > 0 && len(results) > size {
+		return results[:size], results[size:]
+	}
+	return results, nil
+}
+
+// 全ての結果の要約が生成済みかを返す
+func summarized(results []model.Result) bool {
+	for _, r := range results {
+		if r.GeneratedSummary == "" && r.SummaryFields == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// 結果を RESULT_PAGE_SIZE 件ずつ投稿し、残りがあれば続きを表示するボタンを付ける
+// 要約が未生成のページは投稿する前に生成する
+func (h *Handler) postResultPage(threadTS string, session pageSession) {
+	page, rest := h.splitPage(session.results)
+	if !summarized(page) && !h.generateSummaries(session.channelID, session.userID, session.messageText, threadTS, page) {
+		return
+	}
+	for _, issue := range page {
+		if _, _, err := h.slackClient.PostMessage(
+			session.channelID,
+			slack.MsgOptionBlocks(h.resultBlocks(session.channelID, issue)...),
+			slack.MsgOptionTS(threadTS),
+			slack.MsgOptionLinkNames(false),
+		); err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+		}
+	}
+	session.results = rest
+	h.postNextPageButton(threadTS, session)
+}
+
+// 未表示の結果を保持し、続きを表示するボタンを投稿する
+func (h *Handler) postNextPageButton(threadTS string, session pageSession) {
+	if len(session.results) == 0 {
+		return
+	}
+	// 要約を後から書き込むため、キャッシュ等と共有しないよう複製して保持する
+	session.results = slices.Clone(session.results)
+	sessionID := newPageSessionID()
+	h.pages.Set(sessionID, session, ttlcache.DefaultTTL)
+	next := min(len(session.results), h.cfg.Handler.ResultPageSize)
+	button := slack.NewButtonBlockElement(nextPageActionID, sessionID,
+		slack.NewTextBlockObject("plain_text", h.messages.get("button_next_page", next), false, false))
+	if _, _, err := h.slackClient.PostMessage(
+		session.channelID,
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject("mrkdwn", h.messages.get("page_remaining", len(session.results)), false, false),
+				nil, nil,
+			),
+			slack.NewActionBlock("", button),
+		),
+		slack.MsgOptionTS(threadTS),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post next page button", slog.Any("err", err))
+	}
+}
+
+// 「次のN件を表示」ボタンが押されたら保持している続きの結果を投稿する
+// 押されたボタンのメッセージは二重に表示しないよう削除する
+func (h *Handler) postNextPage(callback slack.InteractionCallback, sessionID string) {
+	channelID := callback.Channel.ID
+	item := h.pages.Get(sessionID)
+	if item == nil || item.Value().channelID != channelID {
+		if _, err := h.slackClient.PostEphemeral(
+			channelID,
+			callback.User.ID,
+			slack.MsgOptionText(h.messages.get("page_expired"), false),
+		); err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post ephemeral message", slog.Any("err", err))
+		}
+		return
+	}
+	h.pages.Delete(sessionID)
+
+	if _, _, err := h.slackClient.DeleteMessage(channelID, callback.Message.Timestamp); err != nil {
+		slog.WarnContext(h.ctx, "Failed to delete next page button", slog.Any("err", err))
+	}
+	h.postResultPage(callback.Message.ThreadTimestamp, item.Value())
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/pyama86/jipcy/domain/service"
+)
+
+// QUIET_MODE が有効、または QUIET_CHANNELS に含まれるチャンネルの場合は
+// 受付・途中経過・処理時間などの通知を抑止し、最終結果とエラーのみ投稿するハンドラを返す
+func (h *Handler) withQuiet(channelID string) *Handler {
+	if !h.cfg.Slack.QuietMode && !h.channelListed(channelID, h.cfg.Slack.QuietChannels) {
+		return h
+	}
+	c := *h
+	c.quiet = true
+	c.verboseSteps = false
+	return &c
+}
+
+// 途中経過の通知設定を引き継いだ類似課題の選定サービスを返す
+func (h *Handler) newSelectService() *service.SelectTopIssueService {
+	svc := service.NewSelectTopIssueService(h.cfg, h.openAI, h.slack, h.jira, h.slackClient, h.exporter)
+	svc.SetVerboseSteps(h.verboseSteps)
+	svc.SetMessages(h.messages.get)
+	return svc
+}
@@ -0,0 +1,29 @@
+package handler
+
+import "log/slog"
+
+// 処理中・完了を示すリアクション
+const (
+	reactionProcessing = "hourglass_flowing_sand"
+	reactionDone       = "white_check_mark"
+)
+
+// 問い合わせのメッセージに処理中のリアクションを付け、終了時に呼び出す関数を返す
+// 完了した場合は完了のリアクションに付け替え、失敗した場合は処理中のリアクションを外すだけにする
+// リアクションの操作に失敗しても問い合わせの処理は継続する
+func (h *Handler) startReaction(channelID, ts string) func(succeeded bool) {
+	if err := h.slack.AddReaction(channelID, ts, reactionProcessing); err != nil {
+		slog.WarnContext(h.ctx, "Failed to add reaction", slog.Any("err", err))
+	}
+	return func(succeeded bool) {
+		if err := h.slack.RemoveReaction(channelID, ts, reactionProcessing); err != nil {
+			slog.WarnContext(h.ctx, "Failed to remove reaction", slog.Any("err", err))
+		}
+		if !succeeded {
+			return
+		}
+		if err := h.slack.AddReaction(channelID, ts, reactionDone); err != nil {
+			slog.WarnContext(h.ctx, "Failed to add reaction", slog.Any("err", err))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"log/slog"
+
+	"github.com/pyama86/jipcy/domain/infra"
+	"github.com/slack-go/slack"
+)
+
+// 検索結果が0件だった場合に、条件を段階的に緩めて再検索する
+// 課題が見つかった段階で緩和した旨を投稿し、その課題と使用したクエリを返す
+func (h *Handler) relaxSearch(channelID, ts string, queries []string) ([]infra.Issue, int, []string) {
+	for _, level := range infra.RelaxLevels {
+		relaxed := h.jira.RelaxQueries(queries, level)
+		if len(relaxed) == 0 {
+			continue
+		}
+		issues, total, err := h.jira.FetchIssuesByQueries(h.ctx, relaxed)
+		if err != nil {
+			slog.WarnContext(h.ctx, "Failed to fetch Jira issues with relaxed queries", slog.Any("queries", relaxed), slog.Any("err", err))
+			continue
+		}
+		slog.InfoContext(h.ctx, "Relaxed Jira search", slog.Int("level", int(level)), slog.Any("queries", relaxed), slog.Int("fetched", len(issues)))
+		if len(issues) == 0 {
+			continue
+		}
+
+		if h.quiet {
+			return issues, total, relaxed
+		}
+		if _, _, err := h.slackClient.PostMessage(
+			channelID,
+			slack.MsgOptionText(h.messages.get(relaxMessageKey(level)), false),
+			slack.MsgOptionTS(ts),
+			slack.MsgOptionLinkNames(false),
+		); err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+		}
+		return issues, total, relaxed
+	}
+	return nil, 0, queries
+}
+
+func relaxMessageKey(level infra.RelaxLevel) string {
+	if level == infra.RelaxProjectOnly {
+		return "relaxed_project_only"
+	}
+	return "relaxed_keywords"
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// 再試行ボタンのaction_id
+	retryActionID = "retry_inquiry"
+	// 再試行後にボタンを取り除くためのblock_id（"retry_inquiry:<問い合わせたユーザーID>" の形式）
+	retryBlockID = "retry_inquiry"
+	// Slackのボタンの value に保持できる最大文字数
+	maxButtonValueLength = 2000
+)
+
+// エラー時に再試行ボタンで再実行する問い合わせ文を保持したHandlerを返す
+func (h *Handler) withRetry(messageText string) *Handler {
+	c := *h
+	c.retryText = messageText
+	return &c
+}
+
+// 元の問い合わせ文を value に、問い合わせたユーザーを block_id に保持した再試行ボタンのブロックを返す
+// 問い合わせ文が value に収まらない場合はボタンを付けない
+func (h *Handler) retryBlock(userID string) (slack.Block, bool) {
+	if h.retryText == "" || len(h.retryText) > maxButtonValueLength {
+		return nil, false
+	}
+	button := slack.NewButtonBlockElement(retryActionID, h.retryText,
+		slack.NewTextBlockObject("plain_text", h.messages.get("button_retry"), false, false))
+	return slack.NewActionBlock(retryBlockID+":"+userID, button), true
+}
+
+// 再試行ボタンの block_id から問い合わせたユーザーのIDを返す
+func retryOwner(blockID string) (string, bool) {
+	owner, ok := strings.CutPrefix(blockID, retryBlockID+":")
+	return owner, ok && owner != ""
+}
+
+// 再試行ボタンが押されたら同じ問い合わせ文で処理をやり直す
+// 問い合わせたユーザーの言語・履歴・キャッシュで実行されるよう、本人以外が押した場合は再試行しない
+// 二重に実行されないよう、エラーのメッセージからボタンを取り除いてから再開する
+func (h *Handler) retryInquiry(callback slack.InteractionCallback, blockID, messageText string) {
+	if messageText == "" {
+		return
+	}
+	channelID := callback.Channel.ID
+	userID := callback.User.ID
+	if owner, ok := retryOwner(blockID); !ok || owner != userID {
+		slog.WarnContext(h.ctx, "Retry from non-owner user", slog.String("user", userID), slog.String("block_id", blockID))
+		if _, err := h.slackClient.PostEphemeral(
+			channelID,
+			userID,
+			slack.MsgOptionText(h.messages.get("retry_not_owner"), false),
+		); err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post ephemeral message", slog.Any("err", err))
+		}
+		return
+	}
+	ts := callback.Message.ThreadTimestamp
+	if ts == "" {
+		ts = callback.Message.Timestamp
+	}
+
+	var blocks []slack.Block
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if _, ok := retryOwner(block.ID()); !ok {
+			blocks = append(blocks, block)
+		}
+	}
+	if _, _, _, err := h.slackClient.UpdateMessage(
+		channelID,
+		callback.Message.Timestamp,
+		slack.MsgOptionBlocks(blocks...),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.WarnContext(h.ctx, "Failed to remove retry button", slog.Any("err", err))
+	}
+
+	slog.InfoContext(h.ctx, "Retry inquiry", slog.String("channel", channelID), slog.String("user", userID))
+	h.dispatchInquiry(channelID, userID, messageText, ts)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// 共有ボタンのaction_id
+	shareActionID = "share_result"
+	// 転記時に取り除くためのblock_id
+	shareBlockID = "share_result"
+)
+
+// DMのチャンネルIDは "D" で始まる
+func isDirectMessage(channelID string) bool {
+	return strings.HasPrefix(channelID, "D")
+}
+
+// 結果の投稿に使うブロックを返す
+// DMでの問い合わせで SHARE_CHANNEL が設定されている場合はそのチャンネルへ共有するボタンを付ける
+// 結果にはユーザートークンで検索した非公開チャンネルのスレッドが含まれ得るため、任意のチャンネルへの共有は許可しない
+func (h *Handler) resultBlocks(channelID string, issue model.Result) []slack.Block {
+	blocks := h.issueBlocks(issue)
+	shareChannel := h.cfg.Handler.ShareChannel
+	if !isDirectMessage(channelID) || shareChannel == "" {
+		return blocks
+	}
+	button := slack.NewButtonBlockElement(shareActionID, shareChannel,
+		slack.NewTextBlockObject("plain_text", h.messages.get("button_share"), false, false))
+	return append(blocks, slack.NewActionBlock(shareBlockID, button))
+}
+
+// DMに投稿された結果を、元がDMであることを示すフッターを付けてチャンネルに転記する
+func (h *Handler) shareResult(callback slack.InteractionCallback, channelID string) {
+	userID := callback.User.ID
+	dmChannelID := callback.Channel.ID
+	if channelID == "" || channelID != h.cfg.Handler.ShareChannel {
+		slog.WarnContext(h.ctx, "Rejected share to unconfigured channel", slog.String("user", userID), slog.String("channel", channelID))
+		return
+	}
+
+	var blocks []slack.Block
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if block.ID() == shareBlockID {
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		slog.WarnContext(h.ctx, "No blocks to share", slog.String("user", userID))
+		return
+	}
+	blocks = append(blocks, slack.NewContextBlock("",
+		slack.NewTextBlockObject("mrkdwn", h.messages.get("share_footer", userID), false, false),
+	))
+
+	text := h.messages.get("share_done", channelID)
+	if _, _, err := h.slackClient.PostMessage(
+		channelID,
+		slack.MsgOptionBlocks(blocks...),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to share result", slog.String("channel", channelID), slog.Any("err", err))
+		text = h.messages.get("error_share", channelID)
+	} else {
+		slog.InfoContext(h.ctx, "Shared result", slog.String("user", userID), slog.String("channel", channelID))
+	}
+	if _, err := h.slackClient.PostEphemeral(
+		dmChannelID,
+		userID,
+		slack.MsgOptionText(text, false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post ephemeral message", slog.Any("err", err))
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Slackのsectionブロックのテキストの上限文字数
+const maxSectionTextLength = 3000
+
+// コードブロックの区切り
+const codeFence = "```"
+
+// 長いテキストを limit 文字以内の複数のテキストに分割する
+// 段落（空行）の境界を優先し、収まらない段落は行単位、それでも収まらない行は文字数で分割する
+// コードブロックの途中で分割した場合は、分割位置でコードブロックを閉じて次のテキストで開き直す
+func splitText(text string, limit int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+	// コードブロックを閉じ直すための余白を確保する
+	packLimit := limit - 2*(len(codeFence)+1)
+
+	var pieces []textPiece
+	for _, paragraph := range splitParagraphs(text) {
+		if utf8.RuneCountInString(paragraph) <= packLimit {
+			pieces = append(pieces, textPiece{text: paragraph, paragraphStart: true})
+			continue
+		}
+		for i, line := range strings.Split(paragraph, "\n") {
+			for j, part := range splitRunes(line, packLimit) {
+				pieces = append(pieces, textPiece{text: part, paragraphStart: i == 0 && j == 0})
+			}
+		}
+	}
+
+	// 区切り文字を含めて上限に収まるよう詰め直す
+	var chunks []string
+	var current strings.Builder
+	var currentLen int
+	for _, piece := range pieces {
+		sep := "\n"
+		if piece.paragraphStart {
+			sep = "\n\n"
+		}
+		pieceLen := utf8.RuneCountInString(piece.text)
+		if currentLen > 0 && currentLen+len(sep)+pieceLen > packLimit {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+		if currentLen > 0 {
+			current.WriteString(sep)
+			currentLen += len(sep)
+		}
+		current.WriteString(piece.text)
+		currentLen += pieceLen
+	}
+	if currentLen > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return balanceCodeFences(chunks)
+}
+
+// 分割したテキストの断片
+type textPiece struct {
+	text string
+	// 段落の先頭の断片かどうか（前の断片とは空行で区切る）
+	paragraphStart bool
+}
+
+// 空行で段落に分割する。コードブロック内の空行では分割しない
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	var current []string
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), codeFence) {
+			inFence = !inFence
+		}
+		if line == "" && !inFence {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, strings.Join(current, "\n"))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, "\n"))
+	}
+	return paragraphs
+}
+
+// 文字数で分割する
+func splitRunes(s string, limit int) []string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return []string{s}
+	}
+	var parts []string
+	for len(runes) > limit {
+		parts = append(parts, string(runes[:limit]))
+		runes = runes[limit:]
+	}
+	return append(parts, string(runes))
+}
+
+// コードブロックの途中で分割されたテキストの末尾でコードブロックを閉じ、次のテキストの先頭で開き直す
+func balanceCodeFences(chunks []string) []string {
+	inFence := false
+	for i, chunk := range chunks {
+		reopen := inFence
+		for _, line := range strings.Split(chunk, "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), codeFence) {
+				inFence = !inFence
+			}
+		}
+		if reopen {
+			chunk = codeFence + "\n" + chunk
+		}
+		if inFence {
+			chunk += "\n" + codeFence
+		}
+		chunks[i] = chunk
+	}
+	return chunks
+}
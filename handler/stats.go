@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/robfig/cron/v3"
+	"github.com/slack-go/slack"
+)
+
+// 日次の利用統計をメモリ上で集計する構造体
+type usageStats struct {
+	mu              sync.Mutex
+	date            string
+	inquiries       int
+	hits            int
+	similaritySum   float64
+	similarityCount int
+}
+
+func newUsageStats() *usageStats {
+	return &usageStats{date: time.Now().Format("2006-01-02")}
+}
+
+// 日付が変わっていたら集計をリセットする（ロック取得済みで呼び出すこと）
+func (s *usageStats) rotate() {
+	today := time.Now().Format("2006-01-02")
+	if s.date != today {
+		s.date = today
+		s.inquiries = 0
+		s.hits = 0
+		s.similaritySum = 0
+		s.similarityCount = 0
+	}
+}
+
+// 問い合わせを1件記録する
+func (s *usageStats) recordInquiry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate()
+	s.inquiries++
+}
+
+// 類似課題が見つかった問い合わせを記録する
+func (s *usageStats) recordHit(results []model.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate()
+	s.hits++
+	for _, r := range results {
+		s.similaritySum += r.Similarity
+		s.similarityCount++
+	}
+}
+
+// 当日の集計結果を指定した言語のSlack投稿用のテキストにする
+func (s *usageStats) summary(m messages) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate()
+
+	var avgSimilarity, hitRate float64
+	if s.similarityCount > 0 {
+		avgSimilarity = s.similaritySum / float64(s.similarityCount)
+	}
+	if s.inquiries > 0 {
+		hitRate = float64(s.hits) / float64(s.inquiries) * 100
+	}
+	return m.get("stats_summary", s.date, s.inquiries, s.hits, hitRate, avgSimilarity)
+}
+
+// STATS_CHANNEL と STATS_CRON が設定されていれば日次サマリの投稿を開始する
+func (h *Handler) startStatsScheduler() error {
+	channel := h.cfg.Handler.StatsChannel
+	spec := h.cfg.Handler.StatsCron
+	if channel == "" || spec == "" {
+		return nil
+	}
+
+	c := cron.New()
+	if _, err := c.AddFunc(spec, func() { h.postStats(channel) }); err != nil {
+		return fmt.Errorf("invalid STATS_CRON %q: %w", spec, err)
+	}
+	c.Start()
+	slog.InfoContext(h.ctx, "Stats scheduler started", slog.String("channel", channel), slog.String("cron", spec))
+	return nil
+}
+
+func (h *Handler) postStats(channelID string) {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			slack.NewTextBlockObject("plain_text", h.messages.get("stats_header"), false, false),
+		),
+		slack.NewDividerBlock(),
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", h.stats.summary(h.messages), false, false),
+			nil, nil,
+		),
+	}
+	if _, _, err := h.slackClient.PostMessage(
+		channelID,
+		slack.MsgOptionBlocks(blocks...),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post stats", slog.Any("err", err))
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pyama86/jipcy/config"
+	"github.com/pyama86/jipcy/domain/infra"
+	"github.com/pyama86/jipcy/domain/service"
+	"github.com/slack-go/slack"
+)
+
+// 管理者コマンドのキーワード
+const statusCommand = "status"
+
+// ADMIN_USERS に含まれるユーザーかを判定する
+func (h *Handler) isAdmin(userID string) bool {
+	return userID != "" && slices.Contains(h.cfg.Handler.AdminUsers, userID)
+}
+
+// 機密情報を先頭の数文字以外伏せ字にする
+func maskSecret(m messages, v string) string {
+	if v == "" {
+		return m.get("status_unset")
+	}
+	const visible = 4
+	if len(v) <= visible*2 {
+		return strings.Repeat("*", len(v))
+	}
+	return v[:visible] + strings.Repeat("*", 8)
+}
+
+func orUnset(m messages, v string) string {
+	if v != "" {
+		return v
+	}
+	return m.get("status_unset")
+}
+
+// 現在の設定を表示用のテキストにする
+func statusText(m messages, cfg *config.Config) string {
+	provider := "OpenAI"
+	if cfg.OpenAI.IsAzure() {
+		provider = "Azure OpenAI"
+	}
+
+	if fallback := cfg.OpenAI.Fallback; fallback != "" {
+		provider += m.get("status_fallback", fallback)
+	}
+
+	lines := []string{
+		m.get("status_provider", provider),
+		m.get("status_model", orUnset(m, cfg.OpenAI.Model)),
+		m.get("status_condense_model", orUnset(m, cfg.OpenAI.CondenseModel)),
+		m.get("status_threshold", service.SimilarityThreshold),
+		m.get("status_max_search", infra.MaxSearchResults),
+		m.get("status_max_results", service.ResultLimit(cfg.Handler.ResultPageSize)),
+		m.get("status_concurrency", cfg.Selection.Concurrency),
+		m.get("status_jira_endpoint", orUnset(m, cfg.Jira.Endpoint)),
+		m.get("status_project_key", orUnset(m, cfg.Jira.ProjectKey)),
+		m.get("status_jira_auth", orUnset(m, cfg.Jira.AuthType)),
+		m.get("status_channel", orUnset(m, cfg.Slack.Channel)),
+	}
+
+	// 機密情報はマスクして表示する
+	secrets := []struct{ key, value string }{
+		{"SLACK_BOT_TOKEN", cfg.Slack.BotToken},
+		{"SLACK_APP_TOKEN", cfg.Slack.AppToken},
+		{"SLACK_SIGNING_SECRET", cfg.Slack.SigningSecret},
+		{"SLACK_USER_TOKEN", cfg.Slack.UserToken},
+		{"JIRA_API_TOKEN", cfg.Jira.APIToken},
+		{"OPENAI_API_KEY", cfg.OpenAI.APIKey},
+		{"AZURE_OPENAI_KEY", cfg.OpenAI.AzureKey},
+	}
+	for _, secret := range secrets {
+		lines = append(lines, fmt.Sprintf("*%s:* `%s`", secret.key, maskSecret(m, secret.value)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Jiraのレートリミットの直近の状態を表示用のテキストにする
+func rateLimitText(m messages, info infra.RateLimitInfo) string {
+	text := m.get("status_rate_limit", info.Remaining, info.Limit)
+	if !info.Reset.IsZero() {
+		text += m.get("status_rate_limit_reset", info.Reset.Local().Format(time.DateTime))
+	}
+	if info.NearLimit {
+		text += " ⚠️"
+	}
+	return text + m.get("status_updated_at", info.UpdatedAt.Local().Format(time.DateTime))
+}
+
+// 管理者からの status コマンドに現在の設定をエフェメラルで返す
+func (h *Handler) postStatus(channelID, userID string) {
+	text := h.messages.get("error_not_admin")
+	if h.isAdmin(userID) {
+		text = h.messages.get("status_header") + "\n" + statusText(h.messages, h.cfg)
+		if info, ok := h.jira.RateLimit(); ok {
+			text += "\n" + rateLimitText(h.messages, info)
+		}
+	} else {
+		slog.WarnContext(h.ctx, "Status command from non-admin user", slog.String("user", userID))
+	}
+	if _, err := h.slackClient.PostEphemeral(
+		channelID,
+		userID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post status", slog.Any("err", err))
+	}
+}
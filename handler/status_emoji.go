@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 未知のステータスに使う絵文字
+const defaultStatusEmoji = "⬜"
+
+// ステータス名と絵文字の既定の対応
+var defaultStatusEmojis = map[string]string{
+	"open":        "🟥",
+	"to do":       "🟥",
+	"backlog":     "🟥",
+	"未着手":         "🟥",
+	"in progress": "🟨",
+	"in review":   "🟨",
+	"進行中":         "🟨",
+	"done":        "🟩",
+	"closed":      "🟩",
+	"resolved":    "🟩",
+	"完了":          "🟩",
+}
+
+type statusEmojiMap map[string]string
+
+// STATUS_EMOJI_MAP (JSON) で既定の対応を上書きする
+func loadStatusEmojiMap(overrides map[string]string) statusEmojiMap {
+	m := make(statusEmojiMap, len(defaultStatusEmojis))
+	for k, v := range defaultStatusEmojis {
+		m[k] = v
+	}
+
+	for k, v := range overrides {
+		m[strings.ToLower(k)] = v
+	}
+	return m
+}
+
+// ステータス名に対応する絵文字を返す。大文字小文字は区別しない
+func (m statusEmojiMap) get(status string) string {
+	if emoji, ok := m[strings.ToLower(strings.TrimSpace(status))]; ok {
+		return emoji
+	}
+	return defaultStatusEmoji
+}
+
+// ステータス絵文字と課題キー付きの課題ヘッダーを組み立てる
+func (h *Handler) issueHeader(key, status string) string {
+	header := h.messages.get("issue_header")
+	if key != "" {
+		header += " " + key
+	}
+	if status == "" {
+		return fmt.Sprintf("%s %s", defaultStatusEmoji, header)
+	}
+	return fmt.Sprintf("%s %s (%s)", h.statusEmoji.get(status), header, status)
+}
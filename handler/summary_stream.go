@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pyama86/jipcy/domain/model"
+	"github.com/slack-go/slack"
+	"golang.org/x/sync/errgroup"
+)
+
+// 要約を生成して結果を投稿する
+// SUMMARY_STREAM が有効な場合は先に結果を投稿し、要約の生成に合わせて逐次更新する
+// RESULT_PAGE_SIZE が設定されている場合は最初のページの要約のみ生成し、続きはボタンが押されたときに生成する
+func (h *Handler) summarizeAndPost(channelID, userID, messageText, ts string, results []model.Result) bool {
+	page, rest := h.splitPage(results)
+	if h.streamSummary {
+		threadTS := h.resultThread(channelID, ts, results)
+		if !h.streamSummaries(channelID, userID, messageText, ts, threadTS, page) {
+			return false
+		}
+		h.postNextPageButton(threadTS, pageSession{channelID: channelID, userID: userID, messageText: messageText, results: rest})
+		return true
+	}
+	if !h.generateSummaries(channelID, userID, messageText, ts, page) {
+		return false
+	}
+	h.postResults(channelID, userID, messageText, ts, results)
+	return true
+}
+
+// 要約生成中のプレースホルダーで結果を投稿し、ストリーミングで受け取った要約で更新する
+func (h *Handler) streamSummaries(channelID, userID, messageText, ts, threadTS string, results []model.Result) bool {
+	messageTS := make([]string, len(results))
+	for i, issue := range results {
+		issue.GeneratedSummary = h.messages.get("summary_streaming")
+		_, postedTS, err := h.slackClient.PostMessage(
+			channelID,
+			slack.MsgOptionBlocks(h.resultBlocks(channelID, issue)...),
+			slack.MsgOptionTS(threadTS),
+			slack.MsgOptionLinkNames(false),
+		)
+		if err != nil {
+			slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+			continue
+		}
+		messageTS[i] = postedTS
+	}
+
+	history := h.conversation.Context(userID)
+	g, gctx := errgroup.WithContext(h.ctx)
+	for i := range results {
+		i := i // ループ変数をキャプチャ
+		g.Go(func() error {
+			updater := newStreamUpdater(h.streamInterval, func(summary string) {
+				issue := results[i]
+				issue.GeneratedSummary = summary
+				issue.SummaryFields = nil
+				h.updateIssueMessage(channelID, messageTS[i], issue)
+			})
+			defer updater.stop()
+
+			onChunk := func(fields model.SummaryFields) {
+				updater.set(h.summaryFieldsText(fields, false))
+			}
+			if err := h.openAI.GenerateSummaryStream(gctx, messageText, h.lang, history, &results[i], onChunk); err != nil {
+				return err
+			}
+			updater.stop()
+			h.updateIssueMessage(channelID, messageTS[i], results[i])
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to generate summary", slog.Any("err", err))
+		h.postError(channelID, userID, h.messages.get("error_summary"), ts, err)
+		return false
+	}
+	return true
+}
+
+// 投稿済みの課題の結果を更新する。投稿に失敗していた場合は新たに投稿しない
+func (h *Handler) updateIssueMessage(channelID, messageTS string, issue model.Result) {
+	if messageTS == "" {
+		return
+	}
+	if _, _, _, err := h.slackClient.UpdateMessage(
+		channelID,
+		messageTS,
+		slack.MsgOptionBlocks(h.resultBlocks(channelID, issue)...),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to update message", slog.Any("err", err))
+	}
+}
+
+// ストリーミング中の要約をバッファリングし、一定間隔で更新処理を呼び出す
+// rate limitを考慮してチャンクごとには更新しない
+type streamUpdater struct {
+	mu       sync.Mutex
+	latest   string
+	changed  bool
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newStreamUpdater(interval time.Duration, update func(string)) *streamUpdater {
+	u := &streamUpdater{done: make(chan struct{})}
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-u.done:
+				return
+			case <-ticker.C:
+				u.mu.Lock()
+				latest, changed := u.latest, u.changed
+				u.changed = false
+				u.mu.Unlock()
+				if changed {
+					update(latest + " ▌")
+				}
+			}
+		}
+	}()
+	return u
+}
+
+func (u *streamUpdater) set(summary string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.latest = summary
+	u.changed = true
+}
+
+// 更新を止め、実行中の更新処理の完了を待つ
+func (u *streamUpdater) stop() {
+	u.stopOnce.Do(func() { close(u.done) })
+	u.wg.Wait()
+}
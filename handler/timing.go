@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/pyama86/jipcy/domain/service"
+	"github.com/slack-go/slack"
+)
+
+// 1回の問い合わせの段階別の処理時間を集計する構造体
+type timing struct {
+	start time.Time
+	steps []timingStep
+}
+
+type timingStep struct {
+	name     string
+	duration time.Duration
+}
+
+func newTiming() *timing {
+	return &timing{start: time.Now()}
+}
+
+// start からの経過時間を段階の処理時間として記録する
+func (t *timing) record(name string, start time.Time) {
+	t.steps = append(t.steps, timingStep{name: name, duration: time.Since(start)})
+}
+
+func (t *timing) text(m messages) string {
+	steps := make([]string, 0, len(t.steps))
+	for _, step := range t.steps {
+		steps = append(steps, m.get("timing_step", step.name, step.duration.Seconds()))
+	}
+	return m.get("timing", time.Since(t.start).Seconds(), strings.Join(steps, "/"))
+}
+
+// SHOW_TIMING が有効な場合に処理時間を投稿する
+func (h *Handler) postTiming(channelID, ts string, t *timing) {
+	text := t.text(h.messages)
+	slog.InfoContext(h.ctx, "Inquiry timing", slog.String("timing", text))
+	if !h.showTiming || h.quiet {
+		return
+	}
+	if _, _, err := h.slackClient.PostMessage(
+		channelID,
+		slack.MsgOptionBlocks(
+			slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", text, false, false)),
+		),
+		slack.MsgOptionTS(ts),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+	}
+}
+
+// SELECT_TIMEOUT により評価を打ち切ったことを投稿する
+func (h *Handler) postSelectTimeout(channelID, ts string, t *service.SelectTimeout) {
+	if _, _, err := h.slackClient.PostMessage(
+		channelID,
+		slack.MsgOptionText(h.messages.get("select_timed_out", t.Timeout.String(), t.Total, t.Processed), false),
+		slack.MsgOptionTS(ts),
+		slack.MsgOptionLinkNames(false),
+	); err != nil {
+		slog.ErrorContext(h.ctx, "Failed to post message", slog.Any("err", err))
+	}
+}
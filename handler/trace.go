@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/pyama86/jipcy/logging"
+	"github.com/slack-go/slack"
+)
+
+// 問い合わせごとにトレースIDを発行し、ユーザー・開始時刻とともにcontextに載せたHandlerを返す
+// これらの値は h.ctx を通じてinfra/serviceのログや通知でも使われる
+func (h *Handler) withTrace(userID string) *Handler {
+	c := *h
+	c.ctx = logging.NewInquiryContext(userID)
+	return &c
+}
+
+// ユーザーからの報告とログを突合できるよう、トレースIDを小さく表示するブロックを返す
+func (h *Handler) traceBlock() (slack.Block, bool) {
+	traceID := logging.TraceID(h.ctx)
+	if traceID == "" {
+		return nil, false
+	}
+	return slack.NewContextBlock("",
+		slack.NewTextBlockObject("mrkdwn", h.messages.get("error_trace_id", traceID), false, false),
+	), true
+}
@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"context"
+	"time"
+)
+
+type userIDKey struct{}
+
+type startTimeKey struct{}
+
+// context にユーザーが無い場合の値
+const DefaultUserID = "unknown"
+
+// WithUserID は問い合わせしたユーザーのIDを載せたcontextを返す
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID はcontextに載せたユーザーIDを返す（無い場合は DefaultUserID）
+func UserID(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(userIDKey{}).(string); ok && id != "" {
+			return id
+		}
+	}
+	return DefaultUserID
+}
+
+// WithStartTime は問い合わせの処理を開始した時刻を載せたcontextを返す
+func WithStartTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, startTimeKey{}, t)
+}
+
+// StartTime はcontextに載せた開始時刻を返す（無い場合はゼロ値）
+func StartTime(ctx context.Context) time.Time {
+	if ctx == nil {
+		return time.Time{}
+	}
+	t, _ := ctx.Value(startTimeKey{}).(time.Time)
+	return t
+}
+
+// Elapsed は開始時刻からの経過時間を返す（開始時刻が無い場合は 0）
+func Elapsed(ctx context.Context) time.Duration {
+	start := StartTime(ctx)
+	if start.IsZero() {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// NewInquiryContext はトレースID・ユーザー・開始時刻を載せた問い合わせ単位のcontextを返す
+func NewInquiryContext(userID string) context.Context {
+	ctx := WithTraceID(context.Background(), NewTraceID())
+	ctx = WithUserID(ctx, userID)
+	return WithStartTime(ctx, time.Now())
+}
@@ -0,0 +1,64 @@
+// Package logging は問い合わせ単位のトレースID・ユーザー・開始時刻をcontextで受け渡し、ログに付与する仕組みを提供する
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+)
+
+type traceIDKey struct{}
+
+// NewTraceID はUUID(v4)形式のトレースIDを生成する
+func NewTraceID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		slog.Warn("Failed to generate trace id", slog.Any("err", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// WithTraceID はトレースIDを載せたcontextを返す
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceID はcontextに載せたトレースIDを返す（無い場合は空文字）
+func TraceID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// traceHandler はcontextにトレースID・ユーザーがあればログに trace_id・user_id として付与する
+type traceHandler struct {
+	slog.Handler
+}
+
+// NewTraceHandler は slog.InfoContext などに渡したcontextのトレースIDを付与するHandlerを返す
+func NewTraceHandler(h slog.Handler) slog.Handler {
+	return traceHandler{Handler: h}
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := TraceID(ctx); id != "" {
+		r.AddAttrs(slog.String("trace_id", id))
+	}
+	if id := UserID(ctx); id != DefaultUserID {
+		r.AddAttrs(slog.String("user_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -6,29 +6,12 @@ import (
 	"os"
 
 	"github.com/joho/godotenv"
+	"github.com/pyama86/jipcy/config"
 	"github.com/pyama86/jipcy/domain/infra"
 	"github.com/pyama86/jipcy/handler"
+	"github.com/pyama86/jipcy/logging"
 )
 
-func validate() {
-	requiredEnv := []string{
-		"SLACK_BOT_TOKEN",
-		"SLACK_APP_TOKEN",
-		"SLACK_USER_TOKEN",
-		"SLACK_WORKSPACE_URL",
-		"JIRA_ENDPOINT",
-		"JIRA_USERNAME",
-		"JIRA_API_TOKEN",
-		"JIRA_PROJECT_KEY",
-	}
-	for _, env := range requiredEnv {
-		if os.Getenv(env) == "" {
-			slog.Error("required environment variable not set", slog.String("env", env))
-			os.Exit(1)
-		}
-	}
-}
-
 func main() {
 	// check exists .env
 	if _, err := os.Stat(".env"); err == nil {
@@ -38,22 +21,51 @@ func main() {
 		}
 	}
 
-	validate()
-	slack := infra.NewSlack()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		slog.Error("Invalid configuration", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	// 問い合わせごとのトレースIDをログに付与する
+	// ダンプは slog.Debug で出力するため、有効な場合はログレベルを下げる
+	level := slog.LevelInfo
+	if cfg.OpenAI.DebugDump {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(logging.NewTraceHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))))
+	if cfg.OpenAI.DebugDump {
+		slog.Warn("OPENAI_DEBUG_DUMP is enabled, prompts and responses will be logged")
+	}
+
+	slack := infra.NewSlack(cfg)
 
-	jira, err := infra.NewJira()
+	// SLACK_WORKSPACE_URL が未設定の場合は team.info から取得する
+	if cfg.Slack.WorkspaceURL == "" {
+		workspaceURL, err := slack.FetchWorkspaceURL()
+		if err != nil {
+			slog.Error("SLACK_WORKSPACE_URL is required because the workspace URL could not be fetched", slog.Any("err", err))
+			os.Exit(1)
+		}
+		cfg.Slack.WorkspaceURL = workspaceURL
+		slog.Info("Workspace URL fetched from team info", slog.String("url", workspaceURL))
+	}
+
+	jira, err := infra.NewJira(cfg)
 	if err != nil {
 		slog.Error("NewJiraAPI failed", slog.Any("err", err))
 		os.Exit(1)
 	}
 
-	openAI, err := infra.NewOpenAI()
+	openAI, err := infra.NewOpenAI(cfg)
 	if err != nil {
 		slog.Error("NewOpenAI failed", slog.Any("err", err))
 		os.Exit(1)
 	}
 
-	h := handler.NewHandler(slack, jira, openAI)
+	webhook := infra.NewWebhook(cfg)
+
+	h := handler.NewHandler(cfg, slack, jira, openAI, webhook)
 
 	slog.Info("Server started")
 	if err := h.Handle(); err != nil {
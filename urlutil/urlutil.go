@@ -0,0 +1,23 @@
+// Package urlutil はURLの比較・重複排除に使う共通処理を提供する
+package urlutil
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Normalize は同じリソースを指すURLが同じ文字列になるよう正規化する
+// スキームとホストを小文字にし、パス末尾のスラッシュを取り除く
+// URLとして解釈できない場合は前後の空白と末尾のスラッシュのみ取り除く
+func Normalize(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return strings.TrimRight(raw, "/")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return u.String()
+}